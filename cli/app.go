@@ -46,6 +46,8 @@ func NewApp(version string) *CloudSidecarApp {
 	app.Version = version
 	app.Usage = "Cloud sidecar cli"
 	app.ErrWriter = os.Stderr
+	// exit codes are handled in main to log errors with logger
+	app.ExitErrHandler = func(_ *cli.Context, _ error) {}
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
 			Name:   "config-path, c",
@@ -112,7 +114,27 @@ func NewApp(version string) *CloudSidecarApp {
 			Usage:  "See sha1 corresponding to your artifacts",
 			Action: sha1Run,
 		},
+		{
+			Name:            sidecars.LimitsShimCommand,
+			Usage:           "Apply limits of a sidecar and run its executable, used internally by launch",
+			Hidden:          true,
+			SkipFlagParsing: true,
+			Action:          execWithLimitsRun,
+		},
+		{
+			Name:   "status",
+			Usage:  "See state of processes started by launch",
+			Action: statusRun,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "Show state as json",
+				},
+			},
+		},
 	}
+	// launcher run this binary with limits shim command to apply limits of sidecars
+	sidecars.RegisterLimitsShim()
 	return app
 }
 
@@ -131,12 +153,31 @@ func sha1Run(c *cli.Context) error {
 	return l.ShowSidecarsSha1()
 }
 
+func statusRun(c *cli.Context) error {
+	log.SetOutput(os.Stderr)
+	loadLogConfig(&config.Sidecars{
+		LogJson:  c.GlobalBool("log-json"),
+		LogLevel: "ERROR",
+		NoColor:  c.GlobalBool("no-color"),
+	})
+	// state file is read directly, config is only used to find base directory
+	_, dir := findConfPathAndDir(c)
+	return sidecars.ShowStatus(os.Stdout, sidecars.StateFilePath(dir), c.Bool("json"))
+}
+
+func execWithLimitsRun(c *cli.Context) error {
+	// stdout belongs to sidecar, errors are only written on stderr
+	log.SetOutput(os.Stderr)
+	return sidecars.ExecWithLimits(c.Args())
+}
+
 func setupRun(c *cli.Context) error {
 	initApp(c)
 	l, err := createLauncher(c, false)
 	if err != nil {
 		return err
 	}
+	defer l.CloseEvents()
 	return l.Setup()
 }
 
@@ -146,6 +187,10 @@ func launchRun(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	defer l.CloseEvents()
+	l.SetConfigLoader(func() (*config.Sidecars, error) {
+		return retrieveConfig(c)
+	})
 	return l.Launch()
 }
 
@@ -155,6 +200,7 @@ func vendorRun(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	defer l.CloseEvents()
 	return l.DownloadArtifacts()
 }
 
@@ -209,6 +255,8 @@ func createLauncher(c *cli.Context, failWhenNoStarter bool) (*sidecars.Launcher,
 	}
 	defaultPort := c.GlobalInt("app-port")
 	l := sidecars.NewLauncher(*conf, cStarter, profileDir, os.Stdout, os.Stderr, defaultPort)
+	// secrets are masked in launcher logs as in processes outputs
+	log.SetOutput(l.RedactWriter(log.StandardLogger().Out))
 	entry.Debug("Finished creating launcher.")
 	return l, nil
 }
@@ -236,9 +284,16 @@ func retrieveConfig(c *cli.Context) (*config.Sidecars, error) {
 			return nil, fmt.Errorf("configuration loading from %s error: %s", confPath, err.Error())
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
+	err = conf.Check()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %s", err.Error())
+	}
 	conf.Dir = baseDir
 	log.WithField("component", "cli").Debug("Finished loading configuration.")
-	return conf, err
+	return conf, nil
 }
 
 func findConfPathAndDir(c *cli.Context) (confPath string, dir string) {
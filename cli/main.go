@@ -2,6 +2,7 @@ package main
 
 import (
 	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
 	"os"
 )
 
@@ -12,6 +13,9 @@ func main() {
 	err := app.Run(os.Args)
 	if err != nil {
 		log.Error(err)
+		if exitErr, ok := err.(cli.ExitCoder); ok {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
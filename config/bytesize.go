@@ -0,0 +1,84 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size written in configuration in bytes or with a binary unit (e.g.: 1024, 512K, 100M, 2G)
+type ByteSize string
+
+var byteSizeUnits = []struct {
+	suffix string
+	factor uint64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+func (b ByteSize) parse() (uint64, error) {
+	s := strings.ToUpper(strings.TrimSpace(string(b)))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+	factor := uint64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			factor = unit.factor
+			s = strings.TrimSuffix(s, unit.suffix)
+			break
+		}
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v > 0 && factor > ^uint64(0)/v {
+		return 0, fmt.Errorf("size is too big")
+	}
+	return v * factor, nil
+}
+
+// UnmarshalJSON accept size as a number of bytes or as a string
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n.String())
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*b = ByteSize(s)
+	return nil
+}
+
+func (b ByteSize) IsSet() bool {
+	return b != ""
+}
+
+func (b ByteSize) Check() error {
+	if b == "" {
+		return nil
+	}
+	if _, err := b.parse(); err != nil {
+		return fmt.Errorf("invalid size '%s': %s", b, err.Error())
+	}
+	return nil
+}
+
+// Value return size in bytes or def if size is not set or invalid
+func (b ByteSize) Value(def uint64) uint64 {
+	if b == "" {
+		return def
+	}
+	v, err := b.parse()
+	if err != nil {
+		return def
+	}
+	return v
+}
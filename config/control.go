@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+type Control struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Socket  string `yaml:"socket" json:"socket"`
+	Listen  string `yaml:"listen" json:"listen"`
+}
+
+func (c Control) Check() error {
+	if c.Listen == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(c.Listen)
+	if err != nil {
+		return fmt.Errorf("listen '%s' is not a valid address: %s", c.Listen, err.Error())
+	}
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("listen '%s' must be on localhost", c.Listen)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package config
+
+import "fmt"
+
+type Diagnostic struct {
+	Lines         int      `yaml:"lines" json:"lines"`
+	StartupWindow Duration `yaml:"startup_window" json:"startup_window"`
+}
+
+func (d Diagnostic) Check() error {
+	if d.Lines < 0 {
+		return fmt.Errorf("lines must not be negative")
+	}
+	if err := d.StartupWindow.Check(); err != nil {
+		return fmt.Errorf("startup_window: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Duration is a duration written in configuration as a go duration string (e.g.: 500ms, 10s, 1m30s)
+type Duration string
+
+func (d Duration) Check() error {
+	if d == "" {
+		return nil
+	}
+	v, err := time.ParseDuration(string(d))
+	if err != nil {
+		return fmt.Errorf("invalid duration '%s': %s", d, err.Error())
+	}
+	if v < 0 {
+		return fmt.Errorf("invalid duration '%s': must not be negative", d)
+	}
+	return nil
+}
+
+// Value return duration or def if duration is not set or invalid
+func (d Duration) Value(def time.Duration) time.Duration {
+	if d == "" {
+		return def
+	}
+	v, err := time.ParseDuration(string(d))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// CheckPositive validate duration like Check and also reject a zero duration when it is set
+func (d Duration) CheckPositive() error {
+	if err := d.Check(); err != nil {
+		return err
+	}
+	if d != "" && d.Value(0) == 0 {
+		return fmt.Errorf("invalid duration '%s': must be greater than 0", d)
+	}
+	return nil
+}
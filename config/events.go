@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type Events struct {
+	File    string   `yaml:"file" json:"file"`
+	Webhook *Webhook `yaml:"webhook" json:"webhook"`
+}
+
+type Webhook struct {
+	URL          string            `yaml:"url" json:"url"`
+	Headers      map[string]string `yaml:"headers" json:"headers"`
+	Timeout      Duration          `yaml:"timeout" json:"timeout"`
+	MaxRetries   *int              `yaml:"max_retries" json:"max_retries"`
+	RetryBackoff Duration          `yaml:"retry_backoff" json:"retry_backoff"`
+	QueueSize    int               `yaml:"queue_size" json:"queue_size"`
+}
+
+func (e Events) Check() error {
+	if e.Webhook == nil {
+		return nil
+	}
+	if err := e.Webhook.Check(); err != nil {
+		return fmt.Errorf("webhook: %s", err.Error())
+	}
+	return nil
+}
+
+func (w Webhook) Check() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return fmt.Errorf("url '%s' is not valid: %s", w.URL, err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url '%s' must be an http or https url", w.URL)
+	}
+	if w.MaxRetries != nil && *w.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+	if w.QueueSize < 0 {
+		return fmt.Errorf("queue_size must not be negative")
+	}
+	if err := w.Timeout.CheckPositive(); err != nil {
+		return fmt.Errorf("timeout: %s", err.Error())
+	}
+	if err := w.RetryBackoff.Check(); err != nil {
+		return fmt.Errorf("retry_backoff: %s", err.Error())
+	}
+	return nil
+}
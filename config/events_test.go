@@ -0,0 +1,48 @@
+package config
+
+import (
+	"github.com/cloudfoundry-community/gautocloud/decoder"
+	"testing"
+)
+
+func TestWebhookMaxRetries(t *testing.T) {
+	var w Webhook
+	if err := decoder.Unmarshal(map[string]interface{}{"url": "http://localhost", "max_retries": 0}, &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.MaxRetries == nil || *w.MaxRetries != 0 {
+		t.Errorf("expected max_retries 0 to be kept to disable retries, got %v", w.MaxRetries)
+	}
+	w = Webhook{}
+	if err := decoder.Unmarshal(map[string]interface{}{"url": "http://localhost"}, &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.MaxRetries != nil {
+		t.Errorf("expected max_retries to be unset, got %d", *w.MaxRetries)
+	}
+	negative := -1
+	if err := (Webhook{URL: "http://localhost", MaxRetries: &negative}).Check(); err == nil {
+		t.Error("expected error for negative max_retries")
+	}
+}
+
+func TestWebhookCheckTimeout(t *testing.T) {
+	tests := []struct {
+		timeout Duration
+		valid   bool
+	}{
+		{timeout: "", valid: true},
+		{timeout: "1s", valid: true},
+		{timeout: "0s", valid: false},
+		{timeout: "-1s", valid: false},
+	}
+	for _, test := range tests {
+		err := Webhook{URL: "http://localhost", Timeout: test.timeout}.Check()
+		if test.valid && err != nil {
+			t.Errorf("unexpected error for timeout '%s': %v", test.timeout, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected error for timeout '%s'", test.timeout)
+		}
+	}
+}
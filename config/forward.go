@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	"syscall"
+)
+
+// ForwardToApp is target name designating app in forwarded signals, a sidecar with this name takes precedence
+const ForwardToApp = "app"
+
+type ForwardSignal struct {
+	Signal string   `yaml:"signal" json:"signal"`
+	To     []string `yaml:"to" json:"to"`
+}
+
+// Check validate signal can be forwarded, signals used by launcher itself cannot be forwarded
+func (f ForwardSignal) Check() error {
+	sig, err := utils.ParseSignal(f.Signal)
+	if err != nil {
+		return err
+	}
+	switch sig {
+	case syscall.SIGINT, syscall.SIGTERM:
+		return fmt.Errorf("signal '%s' stops launcher and cannot be forwarded", f.Signal)
+	case syscall.SIGKILL:
+		return fmt.Errorf("signal '%s' cannot be caught and cannot be forwarded", f.Signal)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+type Health struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Port    int    `yaml:"port" json:"port"`
+	Path    string `yaml:"path" json:"path"`
+	Bind    string `yaml:"bind" json:"bind"`
+}
+
+func (h Health) Check() error {
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("port '%d' is not a valid port", h.Port)
+	}
+	if h.Bind != "" && net.ParseIP(h.Bind) == nil {
+		return fmt.Errorf("bind '%s' is not a valid ip address", h.Bind)
+	}
+	if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+		return fmt.Errorf("path '%s' must start with /", h.Path)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestHealthCheckBind(t *testing.T) {
+	tests := []struct {
+		bind  string
+		valid bool
+	}{
+		{bind: "", valid: true},
+		{bind: "127.0.0.1", valid: true},
+		{bind: "::1", valid: true},
+		{bind: "localhost", valid: false},
+		{bind: "127.0.0.1:8080", valid: false},
+	}
+	for _, test := range tests {
+		err := Health{Bind: test.bind}.Check()
+		if test.valid && err != nil {
+			t.Errorf("unexpected error for bind '%s': %v", test.bind, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected error for bind '%s'", test.bind)
+		}
+	}
+}
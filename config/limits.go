@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"github.com/cloudfoundry-community/gautocloud/decoder"
+	"time"
+)
+
+// Limits are resource limits applied to a sidecar process, each one sets both soft and hard limits unless a hard limit is set
+type Limits struct {
+	Nofile      int      `yaml:"nofile" json:"nofile"`
+	NofileHard  int      `yaml:"nofile_hard" json:"nofile_hard"`
+	AS          ByteSize `yaml:"as" json:"as"`
+	ASHard      ByteSize `yaml:"as_hard" json:"as_hard"`
+	CPU         Duration `yaml:"cpu" json:"cpu"`
+	CPUHard     Duration `yaml:"cpu_hard" json:"cpu_hard"`
+	Core        ByteSize `yaml:"core" json:"core"`
+	CoreHard    ByteSize `yaml:"core_hard" json:"core_hard"`
+	Nice        *int     `yaml:"nice" json:"nice"`
+	OomScoreAdj *int     `yaml:"oom_score_adj" json:"oom_score_adj"`
+}
+
+// UnmarshalCloud accept sizes given as a number of bytes
+func (l *Limits) UnmarshalCloud(data interface{}) error {
+	type plain Limits
+	values := make(map[string]interface{})
+	for k, v := range data.(map[string]interface{}) {
+		values[k] = v
+	}
+	for _, key := range []string{"as", "as_hard", "core", "core_hard"} {
+		if v, ok := values[key]; ok && v != nil {
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return decoder.Unmarshal(values, (*plain)(l))
+}
+
+func (l Limits) Check() error {
+	if l.Nofile < 0 || l.NofileHard < 0 {
+		return fmt.Errorf("nofile must not be negative")
+	}
+	if l.NofileHard > 0 && l.Nofile == 0 {
+		return fmt.Errorf("nofile_hard requires nofile")
+	}
+	if l.NofileHard > 0 && l.NofileHard < l.Nofile {
+		return fmt.Errorf("nofile_hard must not be lower than nofile")
+	}
+	for _, size := range []struct {
+		name       string
+		soft, hard ByteSize
+	}{{"as", l.AS, l.ASHard}, {"core", l.Core, l.CoreHard}} {
+		if err := size.soft.Check(); err != nil {
+			return fmt.Errorf("%s: %s", size.name, err.Error())
+		}
+		if err := size.hard.Check(); err != nil {
+			return fmt.Errorf("%s_hard: %s", size.name, err.Error())
+		}
+		if size.hard.IsSet() && !size.soft.IsSet() {
+			return fmt.Errorf("%s_hard requires %s", size.name, size.name)
+		}
+		if size.hard.IsSet() && size.hard.Value(0) < size.soft.Value(0) {
+			return fmt.Errorf("%s_hard must not be lower than %s", size.name, size.name)
+		}
+	}
+	if err := l.CPU.Check(); err != nil {
+		return fmt.Errorf("cpu: %s", err.Error())
+	}
+	if err := l.CPUHard.Check(); err != nil {
+		return fmt.Errorf("cpu_hard: %s", err.Error())
+	}
+	if l.CPU != "" && l.CPU.Value(0) < time.Second {
+		return fmt.Errorf("cpu must be at least 1s")
+	}
+	if l.CPUHard != "" && l.CPU == "" {
+		return fmt.Errorf("cpu_hard requires cpu")
+	}
+	if l.CPUHard != "" && l.CPUHard.Value(0) < l.CPU.Value(0) {
+		return fmt.Errorf("cpu_hard must not be lower than cpu")
+	}
+	if l.Nice != nil && (*l.Nice < -20 || *l.Nice > 19) {
+		return fmt.Errorf("nice must be between -20 and 19")
+	}
+	if l.OomScoreAdj != nil && (*l.OomScoreAdj < -1000 || *l.OomScoreAdj > 1000) {
+		return fmt.Errorf("oom_score_adj must be between -1000 and 1000")
+	}
+	return nil
+}
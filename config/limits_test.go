@@ -0,0 +1,44 @@
+package config
+
+import "testing"
+
+func TestLimitsCheckHard(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		valid  bool
+	}{
+		{name: "soft only", limits: Limits{Nofile: 1024, AS: "512M", CPU: "1h", Core: "0"}, valid: true},
+		{name: "hard above soft", limits: Limits{Nofile: 1024, NofileHard: 4096, AS: "512M", ASHard: "1G", CPU: "1h", CPUHard: "2h", Core: "0", CoreHard: "1M"}, valid: true},
+		{name: "hard equal soft", limits: Limits{Nofile: 1024, NofileHard: 1024}, valid: true},
+		{name: "nofile hard below soft", limits: Limits{Nofile: 1024, NofileHard: 512}},
+		{name: "as hard below soft", limits: Limits{AS: "1G", ASHard: "512M"}},
+		{name: "cpu hard below soft", limits: Limits{CPU: "2h", CPUHard: "1h"}},
+		{name: "core hard below soft", limits: Limits{Core: "1M", CoreHard: "0"}},
+		{name: "nofile hard without soft", limits: Limits{NofileHard: 1024}},
+		{name: "as hard without soft", limits: Limits{ASHard: "1G"}},
+		{name: "cpu hard without soft", limits: Limits{CPUHard: "1h"}},
+		{name: "core hard without soft", limits: Limits{CoreHard: "0"}},
+		{name: "negative nofile hard", limits: Limits{Nofile: 1024, NofileHard: -1}},
+	}
+	for _, test := range tests {
+		err := test.limits.Check()
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestLimitsUnmarshalCloudHard(t *testing.T) {
+	var l Limits
+	err := l.UnmarshalCloud(map[string]interface{}{"as": 1024, "as_hard": 2048, "core": 0, "core_hard": "1M", "nofile_hard": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.AS != "1024" || l.ASHard != "2048" || l.Core != "0" || l.CoreHard != "1M" || l.NofileHard != 10 {
+		t.Errorf("unexpected limits: %+v", l)
+	}
+}
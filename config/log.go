@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"github.com/cloudfoundry-community/gautocloud/decoder"
+)
+
+// SidecarLog route output of a sidecar to a log file which is rotated by size or by age
+type SidecarLog struct {
+	Console  bool     `yaml:"console" json:"console"`
+	MaxSize  ByteSize `yaml:"max_size" json:"max_size"`
+	MaxAge   Duration `yaml:"max_age" json:"max_age"`
+	MaxFiles int      `yaml:"max_files" json:"max_files"`
+	Compress bool     `yaml:"compress" json:"compress"`
+}
+
+// UnmarshalCloud accept max size given as a number of bytes
+func (l *SidecarLog) UnmarshalCloud(data interface{}) error {
+	type plain SidecarLog
+	values := make(map[string]interface{})
+	for k, v := range data.(map[string]interface{}) {
+		values[k] = v
+	}
+	if v, ok := values["max_size"]; ok && v != nil {
+		values["max_size"] = fmt.Sprint(v)
+	}
+	return decoder.Unmarshal(values, (*plain)(l))
+}
+
+func (l SidecarLog) Check() error {
+	if err := l.MaxSize.Check(); err != nil {
+		return fmt.Errorf("max_size: %s", err.Error())
+	}
+	if l.MaxSize.IsSet() && l.MaxSize.Value(0) == 0 {
+		return fmt.Errorf("max_size must not be 0")
+	}
+	if err := l.MaxAge.Check(); err != nil {
+		return fmt.Errorf("max_age: %s", err.Error())
+	}
+	if l.MaxFiles < 0 {
+		return fmt.Errorf("max_files must not be negative")
+	}
+	return nil
+}
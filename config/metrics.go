@@ -0,0 +1,21 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+type Metrics struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Listen  string `yaml:"listen" json:"listen"`
+}
+
+func (m Metrics) Check() error {
+	if m.Listen == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(m.Listen); err != nil {
+		return fmt.Errorf("listen '%s' is not a valid address: %s", m.Listen, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var portNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+// reservedPortNames are names of ports given to reverse proxies by launcher
+var reservedPortNames = []string{"listen", "proxy_app"}
+
+// ParsePort give name and fixed port of a port declaration written as name or name:port,
+// port is 0 when it must be allocated by launcher
+func ParsePort(decl string) (string, int, error) {
+	name, portStr, hasPort := strings.Cut(decl, ":")
+	if !portNameRegex.MatchString(name) {
+		return "", 0, fmt.Errorf("port name '%s' must start with a letter and contain only letters, digits, - or _", name)
+	}
+	for _, reserved := range reservedPortNames {
+		if strings.EqualFold(name, reserved) {
+			return "", 0, fmt.Errorf("port name '%s' is reserved", name)
+		}
+	}
+	if !hasPort {
+		return name, 0, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("port '%s' of port %s is not a valid port", portStr, name)
+	}
+	return name, port, nil
+}
+
+func checkPorts(ports []string) error {
+	names := make(map[string]bool)
+	for _, decl := range ports {
+		name, _, err := ParsePort(decl)
+		if err != nil {
+			return err
+		}
+		if names[strings.ToLower(name)] {
+			return fmt.Errorf("port %s is declared more than once", name)
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+)
+
+type Probe struct {
+	TCP              string   `yaml:"tcp" json:"tcp"`
+	HTTP             string   `yaml:"http" json:"http"`
+	HTTPStatus       int      `yaml:"http_status" json:"http_status"`
+	Exec             []string `yaml:"exec" json:"exec"`
+	File             string   `yaml:"file" json:"file"`
+	Timeout          Duration `yaml:"timeout" json:"timeout"`
+	Interval         Duration `yaml:"interval" json:"interval"`
+	CheckTimeout     Duration `yaml:"check_timeout" json:"check_timeout"`
+	InitialDelay     Duration `yaml:"initial_delay" json:"initial_delay"`
+	FailureThreshold int      `yaml:"failure_threshold" json:"failure_threshold"`
+}
+
+func (p Probe) Check() error {
+	nbChecks := 0
+	if p.TCP != "" {
+		nbChecks++
+	}
+	if p.HTTP != "" {
+		nbChecks++
+	}
+	if len(p.Exec) > 0 {
+		nbChecks++
+	}
+	if p.File != "" {
+		nbChecks++
+	}
+	if nbChecks != 1 {
+		return fmt.Errorf("probe must define exactly one of tcp, http, exec or file")
+	}
+	if p.HTTPStatus != 0 && (p.HTTPStatus < 100 || p.HTTPStatus > 599) {
+		return fmt.Errorf("probe http_status '%d' is not a valid http status", p.HTTPStatus)
+	}
+	if p.FailureThreshold < 0 {
+		return fmt.Errorf("probe failure_threshold must not be negative")
+	}
+	if err := p.InitialDelay.Check(); err != nil {
+		return fmt.Errorf("probe initial_delay: %s", err.Error())
+	}
+	// a zero interval would run probe in a loop and a zero timeout would make probe always fail
+	if err := p.Timeout.CheckPositive(); err != nil {
+		return fmt.Errorf("probe timeout: %s", err.Error())
+	}
+	if err := p.Interval.CheckPositive(); err != nil {
+		return fmt.Errorf("probe interval: %s", err.Error())
+	}
+	if err := p.CheckTimeout.CheckPositive(); err != nil {
+		return fmt.Errorf("probe check_timeout: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestProbeCheckDurations(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   Probe
+		wantErr bool
+	}{
+		{"defaults", Probe{TCP: "127.0.0.1:8080"}, false},
+		{"valid durations", Probe{TCP: "127.0.0.1:8080", Interval: "500ms", CheckTimeout: "2s", Timeout: "1m"}, false},
+		{"zero initial delay", Probe{TCP: "127.0.0.1:8080", InitialDelay: "0s"}, false},
+		{"zero interval", Probe{TCP: "127.0.0.1:8080", Interval: "0s"}, true},
+		{"zero interval without unit", Probe{TCP: "127.0.0.1:8080", Interval: "0"}, true},
+		{"negative interval", Probe{TCP: "127.0.0.1:8080", Interval: "-1s"}, true},
+		{"zero check timeout", Probe{TCP: "127.0.0.1:8080", CheckTimeout: "0s"}, true},
+		{"negative check timeout", Probe{TCP: "127.0.0.1:8080", CheckTimeout: "-5s"}, true},
+		{"zero timeout", Probe{TCP: "127.0.0.1:8080", Timeout: "0s"}, true},
+		{"invalid interval", Probe{TCP: "127.0.0.1:8080", Interval: "often"}, true},
+		{"no check", Probe{}, true},
+		{"two checks", Probe{TCP: "127.0.0.1:8080", File: "/tmp/ready"}, true},
+	}
+	for _, test := range tests {
+		err := test.probe.Check()
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+type Redact struct {
+	Patterns       []string `yaml:"patterns" json:"patterns"`
+	EnvKeys        []string `yaml:"env_keys" json:"env_keys"`
+	NoVcapServices bool     `yaml:"no_vcap_services" json:"no_vcap_services"`
+	Mask           string   `yaml:"mask" json:"mask"`
+}
+
+func (r Redact) Check() error {
+	for _, pattern := range r.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("pattern '%s' is not a valid regex: %s", pattern, err.Error())
+		}
+	}
+	for _, key := range r.EnvKeys {
+		if _, err := path.Match(key, ""); err != nil {
+			return fmt.Errorf("env key '%s' is not a valid pattern: %s", key, err.Error())
+		}
+	}
+	return nil
+}
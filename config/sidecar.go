@@ -4,16 +4,52 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/cloudfoundry-community/gautocloud/decoder"
+	"github.com/orange-cloudfoundry/cloud-sidecars/cron"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	"strings"
+	"syscall"
+)
+
+const (
+	SidecarTypeService = "service"
+	SidecarTypeInit    = "init"
+)
+
+const (
+	OverlapSkip  = "skip"
+	OverlapQueue = "queue"
+	OverlapKill  = "kill"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJson = "json"
+)
+
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
 )
 
 type Sidecars struct {
-	Sidecars  []*Sidecar `yaml:"sidecars" json:"sidecars"`
-	NoStarter bool       `yaml:"no_starter" json:"no_starter"`
-	LogLevel  string     `json:"log_level" yaml:"log_level"`
-	Dir       string     `json:"dir" yaml:"dir"`
-	LogJson   bool       `json:"log_json" yaml:"log_json"`
-	NoColor   bool       `json:"no_color" yaml:"no_color"`
-	AppPort   int        `json:"app_port" yaml:"app_port"`
+	Sidecars        []*Sidecar      `yaml:"sidecars" json:"sidecars"`
+	NoStarter       bool            `yaml:"no_starter" json:"no_starter"`
+	LogLevel        string          `json:"log_level" yaml:"log_level"`
+	Dir             string          `json:"dir" yaml:"dir"`
+	LogJson         bool            `json:"log_json" yaml:"log_json"`
+	NoColor         bool            `json:"no_color" yaml:"no_color"`
+	AppPort         int             `json:"app_port" yaml:"app_port"`
+	ShutdownTimeout Duration        `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	Control         *Control        `json:"control" yaml:"control"`
+	Metrics         *Metrics        `json:"metrics" yaml:"metrics"`
+	Health          *Health         `json:"health" yaml:"health"`
+	Diagnostic      *Diagnostic     `json:"diagnostic" yaml:"diagnostic"`
+	Events          *Events         `json:"events" yaml:"events"`
+	Subreaper       bool            `json:"subreaper" yaml:"subreaper"`
+	ForwardSignals  []ForwardSignal `json:"forward_signals" yaml:"forward_signals"`
+	Syslog          *Syslog         `json:"syslog" yaml:"syslog"`
+	Redact          *Redact         `json:"redact" yaml:"redact"`
 }
 
 type Sidecar struct {
@@ -29,8 +65,162 @@ type Sidecar struct {
 	ProfileD            string            `yaml:"profiled" json:"profiled"`
 	WorkDir             string            `yaml:"work_dir" json:"work_dir"`
 	NoLogPrefix         bool              `yaml:"no_log_prefix" json:"no_log_prefix"`
+	LogFormat           string            `yaml:"log_format" json:"log_format"`
+	Log                 *SidecarLog       `yaml:"log" json:"log"`
 	IsRproxy            bool              `yaml:"is_rproxy" json:"is_rproxy"`
 	NoInterruptWhenStop bool              `yaml:"no_interrupt_when_stop" json:"no_interrupt_when_stop"`
+	Restart             string            `yaml:"restart" json:"restart"`
+	RestartMaxRetries   int               `yaml:"restart_max_retries" json:"restart_max_retries"`
+	RestartBackoff      Duration          `yaml:"restart_backoff" json:"restart_backoff"`
+	RestartMaxBackoff   Duration          `yaml:"restart_max_backoff" json:"restart_max_backoff"`
+	RestartResetWindow  Duration          `yaml:"restart_reset_window" json:"restart_reset_window"`
+	Readiness           *Probe            `yaml:"readiness" json:"readiness"`
+	Liveness            *Probe            `yaml:"liveness" json:"liveness"`
+	DependsOn           []string          `yaml:"depends_on" json:"depends_on"`
+	StopSignal          string            `yaml:"stop_signal" json:"stop_signal"`
+	StopTimeout         Duration          `yaml:"stop_timeout" json:"stop_timeout"`
+	Type                string            `yaml:"type" json:"type"`
+	Timeout             Duration          `yaml:"timeout" json:"timeout"`
+	Schedule            string            `yaml:"schedule" json:"schedule"`
+	ScheduleOverlap     string            `yaml:"schedule_overlap" json:"schedule_overlap"`
+	Critical            bool              `yaml:"critical" json:"critical"`
+	Limits              *Limits           `yaml:"limits" json:"limits"`
+	User                string            `yaml:"user" json:"user"`
+	Group               string            `yaml:"group" json:"group"`
+	Groups              []string          `yaml:"groups" json:"groups"`
+	Ports               []string          `yaml:"ports" json:"ports"`
+}
+
+// Check validate sidecars together, names must be unique
+// and dependencies between sidecars must exist and must not create a cycle
+func (c Sidecars) Check() error {
+	names := make(map[string]bool)
+	for _, sidecar := range c.Sidecars {
+		if names[sidecar.Name] {
+			return fmt.Errorf("sidecar name %s is used more than once", sidecar.Name)
+		}
+		names[sidecar.Name] = true
+	}
+	for _, sidecar := range c.Sidecars {
+		for _, dep := range sidecar.DependsOn {
+			if dep == sidecar.Name {
+				return fmt.Errorf("sidecar %s cannot depend on itself", sidecar.Name)
+			}
+			if !names[dep] {
+				return fmt.Errorf("sidecar %s depends on sidecar %s which doesn't exist", sidecar.Name, dep)
+			}
+			if sidecar.IsInit() && !c.sidecarByName(dep).IsInit() {
+				return fmt.Errorf("init sidecar %s can only depend on init sidecars but depends on %s", sidecar.Name, dep)
+			}
+			if c.sidecarByName(dep).IsScheduled() {
+				return fmt.Errorf("sidecar %s cannot depend on scheduled sidecar %s", sidecar.Name, dep)
+			}
+		}
+	}
+	if err := c.ShutdownTimeout.Check(); err != nil {
+		return fmt.Errorf("shutdown_timeout: %s", err.Error())
+	}
+	if c.Control != nil {
+		if err := c.Control.Check(); err != nil {
+			return fmt.Errorf("control: %s", err.Error())
+		}
+	}
+	if c.Metrics != nil {
+		if err := c.Metrics.Check(); err != nil {
+			return fmt.Errorf("metrics: %s", err.Error())
+		}
+	}
+	if c.Health != nil {
+		if err := c.Health.Check(); err != nil {
+			return fmt.Errorf("health: %s", err.Error())
+		}
+	}
+	if c.Diagnostic != nil {
+		if err := c.Diagnostic.Check(); err != nil {
+			return fmt.Errorf("diagnostic: %s", err.Error())
+		}
+	}
+	if c.Events != nil {
+		if err := c.Events.Check(); err != nil {
+			return fmt.Errorf("events: %s", err.Error())
+		}
+	}
+	if c.Syslog != nil {
+		if err := c.Syslog.Check(); err != nil {
+			return fmt.Errorf("syslog: %s", err.Error())
+		}
+	}
+	if c.Redact != nil {
+		if err := c.Redact.Check(); err != nil {
+			return fmt.Errorf("redact: %s", err.Error())
+		}
+	}
+	forwarded := make(map[syscall.Signal]bool)
+	for _, forward := range c.ForwardSignals {
+		if err := forward.Check(); err != nil {
+			return fmt.Errorf("forward_signals: %s", err.Error())
+		}
+		sig, _ := utils.ParseSignal(forward.Signal)
+		if forwarded[sig] {
+			return fmt.Errorf("forward_signals: signal '%s' is forwarded more than once", forward.Signal)
+		}
+		forwarded[sig] = true
+		for _, to := range forward.To {
+			if !names[to] && to != ForwardToApp {
+				return fmt.Errorf("forward_signals: signal '%s' is forwarded to sidecar %s which doesn't exist", forward.Signal, to)
+			}
+		}
+	}
+	_, err := SortByDependencies(c.Sidecars)
+	return err
+}
+
+func (c Sidecars) sidecarByName(name string) *Sidecar {
+	for _, sidecar := range c.Sidecars {
+		if sidecar.Name == name {
+			return sidecar
+		}
+	}
+	return nil
+}
+
+// SortByDependencies give sidecars in an order where each sidecar is placed after sidecars it depends on,
+// order from configuration is kept when sidecars doesn't depend on each other.
+// It returns an error if there is a dependency cycle.
+func SortByDependencies(sidecars []*Sidecar) ([]*Sidecar, error) {
+	sorted := make([]*Sidecar, 0, len(sidecars))
+	placed := make(map[string]bool)
+	for len(sorted) < len(sidecars) {
+		placedOne := false
+		for _, sidecar := range sidecars {
+			if placed[sidecar.Name] || !dependenciesPlaced(sidecar, placed) {
+				continue
+			}
+			placed[sidecar.Name] = true
+			sorted = append(sorted, sidecar)
+			placedOne = true
+			break
+		}
+		if !placedOne {
+			cycle := make([]string, 0)
+			for _, sidecar := range sidecars {
+				if !placed[sidecar.Name] {
+					cycle = append(cycle, sidecar.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle found between sidecars: %s", strings.Join(cycle, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+func dependenciesPlaced(sidecar *Sidecar, placed map[string]bool) bool {
+	for _, dep := range sidecar.DependsOn {
+		if !placed[dep] {
+			return false
+		}
+	}
+	return true
 }
 
 func (c Sidecar) Check() error {
@@ -40,9 +230,119 @@ func (c Sidecar) Check() error {
 	if c.Executable == "" {
 		return fmt.Errorf("you must provide an executable path to your sidecar")
 	}
+	switch c.Restart {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf(
+			"restart policy '%s' for sidecar %s is invalid, it must be one of: %s, %s, %s",
+			c.Restart, c.Name, RestartNever, RestartOnFailure, RestartAlways,
+		)
+	}
+	switch c.Type {
+	case "", SidecarTypeService:
+	case SidecarTypeInit:
+		if c.IsRproxy || c.Readiness != nil || c.Liveness != nil || (c.Restart != "" && c.Restart != RestartNever) {
+			return fmt.Errorf("init sidecar %s cannot be a reverse proxy, have probes or a restart policy", c.Name)
+		}
+	default:
+		return fmt.Errorf(
+			"type '%s' for sidecar %s is invalid, it must be one of: %s, %s",
+			c.Type, c.Name, SidecarTypeService, SidecarTypeInit,
+		)
+	}
+	if c.Critical && (c.IsInit() || c.Schedule != "") {
+		return fmt.Errorf("sidecar %s cannot be critical, init and scheduled sidecars are not always running", c.Name)
+	}
+	if c.Schedule != "" {
+		if c.IsInit() || c.IsRproxy || c.Readiness != nil || c.Liveness != nil || (c.Restart != "" && c.Restart != RestartNever) {
+			return fmt.Errorf("scheduled sidecar %s cannot be an init sidecar, a reverse proxy, have probes or a restart policy", c.Name)
+		}
+		if _, err := cron.Parse(c.Schedule); err != nil {
+			return fmt.Errorf("sidecar %s: %s", c.Name, err.Error())
+		}
+	}
+	switch c.ScheduleOverlap {
+	case "", OverlapSkip, OverlapQueue, OverlapKill:
+	default:
+		return fmt.Errorf(
+			"schedule_overlap '%s' for sidecar %s is invalid, it must be one of: %s, %s, %s",
+			c.ScheduleOverlap, c.Name, OverlapSkip, OverlapQueue, OverlapKill,
+		)
+	}
+	switch c.LogFormat {
+	case "", LogFormatText, LogFormatJson:
+	default:
+		return fmt.Errorf(
+			"log_format '%s' for sidecar %s is invalid, it must be one of: %s, %s",
+			c.LogFormat, c.Name, LogFormatText, LogFormatJson,
+		)
+	}
+	if c.RestartMaxRetries < 0 {
+		return fmt.Errorf("restart_max_retries for sidecar %s must not be negative", c.Name)
+	}
+	if c.StopSignal != "" {
+		if _, err := utils.ParseSignal(c.StopSignal); err != nil {
+			return fmt.Errorf("sidecar %s stop_signal: %s", c.Name, err.Error())
+		}
+	}
+	for _, d := range []Duration{c.RestartResetWindow, c.StopTimeout, c.Timeout} {
+		if err := d.Check(); err != nil {
+			return fmt.Errorf("sidecar %s: %s", c.Name, err.Error())
+		}
+	}
+	// a zero backoff would restart a crashing sidecar in a loop without any delay
+	if err := c.RestartBackoff.CheckPositive(); err != nil {
+		return fmt.Errorf("sidecar %s restart_backoff: %s", c.Name, err.Error())
+	}
+	if err := c.RestartMaxBackoff.CheckPositive(); err != nil {
+		return fmt.Errorf("sidecar %s restart_max_backoff: %s", c.Name, err.Error())
+	}
+	if err := checkPorts(c.Ports); err != nil {
+		return fmt.Errorf("sidecar %s ports: %s", c.Name, err.Error())
+	}
+	if c.HasCredential() {
+		if err := utils.CheckCredential(c.User, c.Group, c.Groups); err != nil {
+			return fmt.Errorf("sidecar %s: %s", c.Name, err.Error())
+		}
+	}
+	if c.Log != nil {
+		if err := c.Log.Check(); err != nil {
+			return fmt.Errorf("sidecar %s log: %s", c.Name, err.Error())
+		}
+	}
+	if c.Limits != nil {
+		if err := c.Limits.Check(); err != nil {
+			return fmt.Errorf("sidecar %s limits: %s", c.Name, err.Error())
+		}
+	}
+	if c.Readiness != nil {
+		if err := c.Readiness.Check(); err != nil {
+			return fmt.Errorf("sidecar %s readiness: %s", c.Name, err.Error())
+		}
+	}
+	if c.Liveness != nil {
+		if err := c.Liveness.Check(); err != nil {
+			return fmt.Errorf("sidecar %s liveness: %s", c.Name, err.Error())
+		}
+	}
 	return nil
 }
 
+// IsInit says if sidecar must be run to completion before starting others sidecars and app
+func (c Sidecar) IsInit() bool {
+	return c.Type == SidecarTypeInit
+}
+
+// HasCredential says if sidecar must run as another user or group than launcher
+func (c Sidecar) HasCredential() bool {
+	return c.User != "" || c.Group != "" || len(c.Groups) > 0
+}
+
+// IsScheduled says if sidecar must be run on a cron schedule
+func (c Sidecar) IsScheduled() bool {
+	return c.Schedule != ""
+}
+
 func (c *Sidecar) UnmarshalCloud(data interface{}) error {
 	type plain Sidecar
 	err := decoder.Unmarshal(data.(map[string]interface{}), (*plain)(c))
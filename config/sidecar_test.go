@@ -0,0 +1,127 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortByDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		sidecars []*Sidecar
+		expected string
+		cycle    bool
+	}{
+		{
+			name:     "without dependencies keep order",
+			sidecars: []*Sidecar{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			expected: "a,b,c",
+		},
+		{
+			name: "dependency started first",
+			sidecars: []*Sidecar{
+				{Name: "api", DependsOn: []string{"db"}},
+				{Name: "db"},
+			},
+			expected: "db,api",
+		},
+		{
+			name: "chain and diamond",
+			sidecars: []*Sidecar{
+				{Name: "front", DependsOn: []string{"api", "auth"}},
+				{Name: "api", DependsOn: []string{"db"}},
+				{Name: "auth", DependsOn: []string{"db"}},
+				{Name: "db"},
+			},
+			expected: "db,api,auth,front",
+		},
+		{
+			name: "cycle",
+			sidecars: []*Sidecar{
+				{Name: "a", DependsOn: []string{"c"}},
+				{Name: "b", DependsOn: []string{"a"}},
+				{Name: "c", DependsOn: []string{"b"}},
+				{Name: "d"},
+			},
+			cycle: true,
+		},
+	}
+	for _, test := range tests {
+		sorted, err := SortByDependencies(test.sidecars)
+		if test.cycle {
+			if err == nil || !strings.Contains(err.Error(), "a, b, c") {
+				t.Errorf("%s: expected cycle error naming a, b and c, got %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		names := make([]string, 0, len(sorted))
+		for _, sidecar := range sorted {
+			names = append(names, sidecar.Name)
+		}
+		if got := strings.Join(names, ","); got != test.expected {
+			t.Errorf("%s: expected order %s, got %s", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestSidecarsCheckDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		sidecars []*Sidecar
+		err      string
+	}{
+		{
+			name: "valid dependencies",
+			sidecars: []*Sidecar{
+				{Name: "api", Executable: "api", DependsOn: []string{"db"}},
+				{Name: "db", Executable: "db"},
+			},
+		},
+		{
+			name: "unknown dependency",
+			sidecars: []*Sidecar{
+				{Name: "api", Executable: "api", DependsOn: []string{"db"}},
+			},
+			err: "depends on sidecar db which doesn't exist",
+		},
+		{
+			name: "depends on itself",
+			sidecars: []*Sidecar{
+				{Name: "api", Executable: "api", DependsOn: []string{"api"}},
+			},
+			err: "cannot depend on itself",
+		},
+		{
+			name: "cycle",
+			sidecars: []*Sidecar{
+				{Name: "api", Executable: "api", DependsOn: []string{"db"}},
+				{Name: "db", Executable: "db", DependsOn: []string{"api"}},
+			},
+			err: "dependency cycle",
+		},
+		{
+			name: "duplicated name",
+			sidecars: []*Sidecar{
+				{Name: "db", Executable: "db"},
+				{Name: "db", Executable: "db"},
+			},
+			err: "used more than once",
+		},
+	}
+	for _, test := range tests {
+		err := Sidecars{Sidecars: test.sidecars}.Check()
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing '%s', got %v", test.name, test.err, err)
+		}
+	}
+}
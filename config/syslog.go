@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+const (
+	SyslogSchemeUDP = "udp"
+	SyslogSchemeTCP = "tcp"
+	SyslogSchemeTLS = "tcp+tls"
+)
+
+type Syslog struct {
+	URL           string   `yaml:"url" json:"url"`
+	Hostname      string   `yaml:"hostname" json:"hostname"`
+	TLSSkipVerify bool     `yaml:"tls_skip_verify" json:"tls_skip_verify"`
+	Timeout       Duration `yaml:"timeout" json:"timeout"`
+	QueueSize     int      `yaml:"queue_size" json:"queue_size"`
+}
+
+func (s Syslog) Check() error {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("url '%s' is not valid: %s", s.URL, err.Error())
+	}
+	switch u.Scheme {
+	case SyslogSchemeUDP, SyslogSchemeTCP, SyslogSchemeTLS:
+	default:
+		return fmt.Errorf(
+			"url '%s' must have one of scheme: %s, %s, %s",
+			s.URL, SyslogSchemeUDP, SyslogSchemeTCP, SyslogSchemeTLS,
+		)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return fmt.Errorf("url '%s' must have a host and a port: %s", s.URL, err.Error())
+	}
+	if s.QueueSize < 0 {
+		return fmt.Errorf("queue_size must not be negative")
+	}
+	if err := s.Timeout.CheckPositive(); err != nil {
+		return fmt.Errorf("timeout: %s", err.Error())
+	}
+	return nil
+}
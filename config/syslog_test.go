@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestSyslogCheckTimeout(t *testing.T) {
+	tests := []struct {
+		timeout Duration
+		valid   bool
+	}{
+		{timeout: "", valid: true},
+		{timeout: "1s", valid: true},
+		{timeout: "0s", valid: false},
+		{timeout: "-1s", valid: false},
+	}
+	for _, test := range tests {
+		err := Syslog{URL: "tcp://localhost:514", Timeout: test.timeout}.Check()
+		if test.valid && err != nil {
+			t.Errorf("unexpected error for timeout '%s': %v", test.timeout, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected error for timeout '%s'", test.timeout)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package sidecars
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const DefaultControlSocket = "control.sock"
+
+func ControlSocketPath(baseDir string, control *config.Control) string {
+	if control != nil && control.Socket != "" {
+		return control.Socket
+	}
+	return filepath.Join(baseDir, PathSidecarsWd, DefaultControlSocket)
+}
+
+type controlServer struct {
+	table      *processTable
+	signalChan chan os.Signal
+	socketPath string
+	listen     string
+	server     *http.Server
+}
+
+func newControlServer(table *processTable, signalChan chan os.Signal, socketPath, listen string) *controlServer {
+	s := &controlServer{
+		table:      table,
+		signalChan: signalChan,
+		socketPath: socketPath,
+		listen:     listen,
+	}
+	s.server = &http.Server{
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+func (s *controlServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /processes", s.listProcesses)
+	mux.HandleFunc("GET /processes/{name}", s.getProcess)
+	mux.HandleFunc("POST /processes/{name}/stop", s.processAction((*process).requestStop))
+	mux.HandleFunc("POST /processes/{name}/start", s.processAction((*process).requestStart))
+	mux.HandleFunc("POST /processes/{name}/restart", s.processAction((*process).requestRestart))
+	mux.HandleFunc("POST /processes/{name}/signal", s.signalProcess)
+	mux.HandleFunc("POST /shutdown", s.shutdown)
+	return mux
+}
+
+// Start listen on unix socket and on tcp address if set, a socket left by a previous launch is removed
+func (s *controlServer) Start() error {
+	entry := log.WithField("component", "Control")
+	if err := os.MkdirAll(filepath.Dir(s.socketPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	listeners := make([]net.Listener, 0)
+	unixListener, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return fmt.Errorf("control socket: %s", err.Error())
+	}
+	listeners = append(listeners, unixListener)
+	if err := os.Chmod(s.socketPath, 0600); err != nil {
+		unixListener.Close()
+		return err
+	}
+	entry.Infof("Control api listening on unix socket %s", s.socketPath)
+	if s.listen != "" {
+		tcpListener, err := net.Listen("tcp", s.listen)
+		if err != nil {
+			unixListener.Close()
+			return fmt.Errorf("control listen: %s", err.Error())
+		}
+		listeners = append(listeners, tcpListener)
+		entry.Infof("Control api listening on %s", s.listen)
+	}
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			err := s.server.Serve(listener)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				entry.Errorf("control api stopped: %v", err)
+			}
+		}(listener)
+	}
+	return nil
+}
+
+func (s *controlServer) Stop() {
+	if err := s.server.Close(); err != nil {
+		log.WithField("component", "Control").Errorf("failed to stop control api: %v", err)
+	}
+	os.Remove(s.socketPath)
+}
+
+type processView struct {
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	State      string     `json:"state"`
+	Pid        int        `json:"pid,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	Uptime     string     `json:"uptime,omitempty"`
+	Restarts   int        `json:"restarts"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	ExitSignal string     `json:"exit_signal,omitempty"`
+}
+
+func newProcessView(status processStatus) processView {
+	view := processView{
+		Name:       status.Name,
+		Type:       status.Type,
+		State:      status.State,
+		Pid:        status.Pid,
+		Restarts:   status.Restarts,
+		ExitSignal: status.ExitSignal,
+	}
+	if status.HasRun() {
+		startedAt := status.StartedAt
+		view.StartedAt = &startedAt
+	}
+	if status.Running {
+		view.Uptime = status.Runtime().Round(time.Second).String()
+	}
+	if !status.Running && status.ExitCode >= 0 {
+		exitCode := status.ExitCode
+		view.ExitCode = &exitCode
+	}
+	return view
+}
+
+func (s *controlServer) listProcesses(w http.ResponseWriter, _ *http.Request) {
+	views := make([]processView, 0)
+	for _, p := range s.table.all() {
+		views = append(views, newProcessView(p.status()))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *controlServer) getProcess(w http.ResponseWriter, req *http.Request) {
+	p := s.findProcess(w, req)
+	if p == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, newProcessView(p.status()))
+}
+
+func (s *controlServer) processAction(action func(*process) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		p := s.findProcess(w, req)
+		if p == nil {
+			return
+		}
+		if err := action(p); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newProcessView(p.status()))
+	}
+}
+
+type signalRequest struct {
+	Signal string `json:"signal"`
+}
+
+func (s *controlServer) signalProcess(w http.ResponseWriter, req *http.Request) {
+	p := s.findProcess(w, req)
+	if p == nil {
+		return
+	}
+	var sigReq signalRequest
+	if err := json.NewDecoder(req.Body).Decode(&sigReq); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %s", err.Error()))
+		return
+	}
+	sig, err := utils.ParseSignal(sigReq.Signal)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !p.status().Running {
+		writeError(w, http.StatusConflict, fmt.Errorf("%s %s is not running", p.typeP, p.name))
+		return
+	}
+	log.WithField(p.typeP, p.name).Infof("Sending signal '%s' to %s %s on request", sig, p.typeP, p.name)
+	if err := p.signal(sig); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newProcessView(p.status()))
+}
+
+func (s *controlServer) shutdown(w http.ResponseWriter, _ *http.Request) {
+	log.WithField("component", "Control").Info("Shutdown requested ...")
+	s.signalChan <- syscall.SIGTERM
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "shutting down"})
+}
+
+func (s *controlServer) findProcess(w http.ResponseWriter, req *http.Request) *process {
+	name := req.PathValue("name")
+	p := s.table.get(name)
+	if p == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("process %s not found", name))
+	}
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithField("component", "Control").Errorf("failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,175 @@
+package sidecars
+
+import (
+	"encoding/json"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startTestSidecar start a sleeping sidecar which can be controlled
+func startTestSidecar(t *testing.T, f *ProcessFactory, name string) *process {
+	p, err := f.FromSidecar(&config.Sidecar{
+		Name:        name,
+		Executable:  "sleep",
+		Args:        []string{"30"},
+		StopTimeout: "1s",
+	}, testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.hold(1)
+	go p.Start()
+	t.Cleanup(p.remove)
+	return p
+}
+
+// controlRequest send a request to control api and decode its response in v if not nil
+func controlRequest(t *testing.T, server *httptest.Server, method, path, body string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: invalid response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func newTestControlServer(processes ...*process) (*httptest.Server, chan os.Signal) {
+	table := newProcessTable()
+	table.set(processes)
+	signalChan := make(chan os.Signal, 1)
+	s := newControlServer(table, signalChan, "", "")
+	return httptest.NewServer(s.handler()), signalChan
+}
+
+func TestControlProcesses(t *testing.T) {
+	f := newTestFactory(t)
+	p := startTestSidecar(t, f, "db")
+	server, _ := newTestControlServer(p)
+	defer server.Close()
+	waitFor(t, 5*time.Second, "sidecar to run", func() bool { return p.status().Running })
+
+	var views []processView
+	if status := controlRequest(t, server, http.MethodGet, "/processes", "", &views); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if len(views) != 1 || views[0].Name != "db" || views[0].State != StateRunning || views[0].Pid == 0 {
+		t.Errorf("expected running sidecar db with a pid, got %+v", views)
+	}
+	var view processView
+	if status := controlRequest(t, server, http.MethodGet, "/processes/db", "", &view); status != http.StatusOK || view.Name != "db" {
+		t.Errorf("expected sidecar db, got status %d and %+v", status, view)
+	}
+	if status := controlRequest(t, server, http.MethodGet, "/processes/unknown", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown process, got %d", status)
+	}
+}
+
+func TestControlStopStartRestart(t *testing.T) {
+	f := newTestFactory(t)
+	p := startTestSidecar(t, f, "db")
+	server, _ := newTestControlServer(p)
+	defer server.Close()
+	// stop is requested as soon as process is running, it must wait for end of this run
+	waitFor(t, 5*time.Second, "sidecar to run", func() bool { return p.status().Running })
+
+	var view processView
+	if status := controlRequest(t, server, http.MethodPost, "/processes/db/stop", "", &view); status != http.StatusOK {
+		t.Fatalf("expected status 200 on stop, got %d", status)
+	}
+	if view.State != StateStopped {
+		t.Errorf("expected sidecar to be stopped when stop returns, got %s", view.State)
+	}
+	if status := controlRequest(t, server, http.MethodPost, "/processes/db/stop", "", nil); status != http.StatusConflict {
+		t.Errorf("expected status 409 when stopping a stopped sidecar, got %d", status)
+	}
+	if status := controlRequest(t, server, http.MethodPost, "/processes/db/start", "", nil); status != http.StatusOK {
+		t.Fatalf("expected status 200 on start, got %d", status)
+	}
+	waitFor(t, 5*time.Second, "sidecar to run again", func() bool { return p.status().Running })
+
+	pid := p.status().Pid
+	if status := controlRequest(t, server, http.MethodPost, "/processes/db/restart", "", nil); status != http.StatusOK {
+		t.Fatalf("expected status 200 on restart, got %d", status)
+	}
+	waitFor(t, 5*time.Second, "sidecar to be restarted", func() bool {
+		status := p.status()
+		return status.Running && status.Pid != pid
+	})
+}
+
+func TestControlRunDoneSetWithRunning(t *testing.T) {
+	f := newTestFactory(t)
+	p := startTestSidecar(t, f, "db")
+	waitFor(t, 5*time.Second, "sidecar to run", func() bool { return p.status().Running })
+	p.mu.Lock()
+	done := p.runDone
+	p.mu.Unlock()
+	if done == nil {
+		t.Fatal("expected end of run to be known as soon as process is running")
+	}
+	select {
+	case <-done:
+		t.Fatal("expected end of run of current run, got end of a previous run")
+	default:
+	}
+}
+
+func TestControlRejectedRequests(t *testing.T) {
+	f := newTestFactory(t)
+	p := startTestSidecar(t, f, "db")
+	app := &process{name: "launcher", typeP: "cloud", doneChan: make(chan struct{})}
+	server, signalChan := newTestControlServer(p, app)
+	defer server.Close()
+	waitFor(t, 5*time.Second, "sidecar to run", func() bool { return p.status().Running })
+
+	if status := controlRequest(t, server, http.MethodPost, "/processes/launcher/stop", "", nil); status != http.StatusConflict {
+		t.Errorf("expected status 409 when stopping app, got %d", status)
+	}
+	if status := controlRequest(t, server, http.MethodPost, "/processes/db/signal", `{"signal":"SIGNOPE"}`, nil); status != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown signal, got %d", status)
+	}
+	if status := controlRequest(t, server, http.MethodPost, "/processes/db/signal", `not json`, nil); status != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid request, got %d", status)
+	}
+	if status := controlRequest(t, server, http.MethodPost, "/shutdown", "", nil); status != http.StatusAccepted {
+		t.Errorf("expected status 202 on shutdown, got %d", status)
+	}
+	select {
+	case sig := <-signalChan:
+		if sig != syscall.SIGTERM {
+			t.Errorf("expected SIGTERM on shutdown, got %s", sig)
+		}
+	default:
+		t.Error("expected launcher to be signaled on shutdown")
+	}
+}
+
+func TestControlSignal(t *testing.T) {
+	f := newTestFactory(t)
+	p := startTestSidecar(t, f, "db")
+	server, _ := newTestControlServer(p)
+	defer server.Close()
+	waitFor(t, 5*time.Second, "sidecar to run", func() bool { return p.status().Running })
+
+	if status := controlRequest(t, server, http.MethodPost, "/processes/db/signal", `{"signal":"SIGKILL"}`, nil); status != http.StatusOK {
+		t.Fatalf("expected status 200 on signal, got %d", status)
+	}
+	waitFor(t, 5*time.Second, "sidecar to be killed", func() bool { return p.status().ExitCode == 128+int(syscall.SIGKILL) })
+}
@@ -0,0 +1,204 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type field struct {
+	name string
+	min  int
+	max  int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	doms     map[int]bool
+	months   map[int]bool
+	dows     map[int]bool
+	domStar  bool
+	dowStar  bool
+	every    time.Duration
+	original string
+}
+
+// Parse a standard cron expression with 5 fields (minute, hour, day of month, month, day of week)
+// each field accepts *, a value, a range (1-5), a list (1,3,5) and a step (*/5 or 1-30/5).
+// Macros (@yearly, @monthly, @weekly, @daily, @hourly) and @every <duration> (e.g.: @every 90s) are also accepted.
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule '%s': %s", spec, err.Error())
+		}
+		if every < time.Second {
+			return nil, fmt.Errorf("invalid schedule '%s': duration must be at least 1s", spec)
+		}
+		return &Schedule{every: every, original: spec}, nil
+	}
+	expr := spec
+	if macro, ok := macros[spec]; ok {
+		expr = macro
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("invalid schedule '%s': expected %d fields but got %d", spec, len(fields), len(parts))
+	}
+	values := make([]map[int]bool, len(fields))
+	for i, part := range parts {
+		v, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule '%s': %s", spec, err.Error())
+		}
+		values[i] = v
+	}
+	// 7 is also sunday
+	if values[4][7] {
+		values[4][0] = true
+		delete(values[4], 7)
+	}
+	s := &Schedule{
+		minutes:  values[0],
+		hours:    values[1],
+		doms:     values[2],
+		months:   values[3],
+		dows:     values[4],
+		domStar:  strings.HasPrefix(parts[2], "*"),
+		dowStar:  strings.HasPrefix(parts[4], "*"),
+		original: spec,
+	}
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule '%s': schedule is never reached", spec)
+	}
+	return s, nil
+}
+
+func parseField(part string, f field) (map[int]bool, error) {
+	values := make(map[int]bool)
+	hi := f.max
+	if f.name == "day of week" {
+		hi = 7
+	}
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		rangePart := item
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step '%s' for %s", item[i+1:], f.name)
+			}
+			rangePart = item[:i]
+		}
+		start, end := f.min, hi
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			start, err = parseValue(bounds[0], f.min, hi, f.name)
+			if err != nil {
+				return nil, err
+			}
+			end, err = parseValue(bounds[1], f.min, hi, f.name)
+			if err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, fmt.Errorf("invalid range '%s' for %s", rangePart, f.name)
+			}
+		default:
+			v, err := parseValue(rangePart, f.min, hi, f.name)
+			if err != nil {
+				return nil, err
+			}
+			start = v
+			end = v
+			if strings.Contains(item, "/") {
+				end = hi
+			}
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+func parseValue(s string, lo, hi int, name string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s' for %s", s, name)
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("value %d for %s must be between %d and %d", v, name, lo, hi)
+	}
+	return v, nil
+}
+
+// Next give next time after t when schedule is reached, it returns zero time if schedule can never be reached
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every).Truncate(time.Second)
+	}
+	t = t.Add(time.Minute).Truncate(time.Minute)
+	// schedule which can't be reached (e.g.: 30 february) are stopped after 5 years of search
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatch(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatch follow cron rule: when both day of month and day of week are restricted, a day matching one of them is valid
+func (s Schedule) dayMatch(t time.Time) bool {
+	domMatch := s.doms[t.Day()]
+	dowMatch := s.dows[int(t.Weekday())]
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (s Schedule) String() string {
+	return s.original
+}
@@ -0,0 +1,260 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"0 0 30 2 *",
+		"@every 500ms",
+		"@every nope",
+		"@unknown",
+	}
+	for _, spec := range specs {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected error for schedule '%s'", spec)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		spec    string
+		minutes []int
+		dows    []int
+	}{
+		{spec: "* * * * *", minutes: seq(0, 59, 1), dows: seq(0, 6, 1)},
+		{spec: "5 * * * *", minutes: []int{5}, dows: seq(0, 6, 1)},
+		{spec: "1-5 * * * *", minutes: seq(1, 5, 1), dows: seq(0, 6, 1)},
+		{spec: "1,3,5 * * * *", minutes: []int{1, 3, 5}, dows: seq(0, 6, 1)},
+		{spec: "*/15 * * * *", minutes: []int{0, 15, 30, 45}, dows: seq(0, 6, 1)},
+		{spec: "10-30/10 * * * *", minutes: []int{10, 20, 30}, dows: seq(0, 6, 1)},
+		{spec: "50/5 * * * *", minutes: []int{50, 55}, dows: seq(0, 6, 1)},
+		{spec: "0 * * * 7", minutes: []int{0}, dows: []int{0}},
+		{spec: "0 * * * 5-7", minutes: []int{0}, dows: []int{0, 5, 6}},
+	}
+	for _, test := range tests {
+		s, err := Parse(test.spec)
+		if err != nil {
+			t.Errorf("unexpected error for schedule '%s': %v", test.spec, err)
+			continue
+		}
+		if !sameValues(s.minutes, test.minutes) {
+			t.Errorf("schedule '%s': expected minutes %v, got %v", test.spec, test.minutes, s.minutes)
+		}
+		if !sameValues(s.dows, test.dows) {
+			t.Errorf("schedule '%s': expected days of week %v, got %v", test.spec, test.dows, s.dows)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := time.UTC
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "next minute",
+			spec: "* * * * *",
+			from: time.Date(2024, 3, 10, 12, 30, 45, 0, utc),
+			want: time.Date(2024, 3, 10, 12, 31, 0, 0, utc),
+		},
+		{
+			name: "never same minute",
+			spec: "30 12 * * *",
+			from: time.Date(2024, 3, 10, 12, 30, 0, 0, utc),
+			want: time.Date(2024, 3, 11, 12, 30, 0, 0, utc),
+		},
+		{
+			name: "step",
+			spec: "*/15 * * * *",
+			from: time.Date(2024, 3, 10, 12, 31, 0, 0, utc),
+			want: time.Date(2024, 3, 10, 12, 45, 0, 0, utc),
+		},
+		{
+			name: "range of hours",
+			spec: "0 9-17 * * *",
+			from: time.Date(2024, 3, 10, 17, 1, 0, 0, utc),
+			want: time.Date(2024, 3, 11, 9, 0, 0, 0, utc),
+		},
+		{
+			name: "hour rollover",
+			spec: "0 * * * *",
+			from: time.Date(2024, 3, 10, 23, 59, 0, 0, utc),
+			want: time.Date(2024, 3, 11, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "month rollover",
+			spec: "0 0 1 * *",
+			from: time.Date(2024, 1, 31, 12, 0, 0, 0, utc),
+			want: time.Date(2024, 2, 1, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "year rollover",
+			spec: "@yearly",
+			from: time.Date(2024, 12, 31, 23, 59, 0, 0, utc),
+			want: time.Date(2025, 1, 1, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "day 31 skips short months",
+			spec: "0 0 31 * *",
+			from: time.Date(2024, 4, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2024, 5, 31, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "29 february on leap year",
+			spec: "0 0 29 2 *",
+			from: time.Date(2025, 3, 1, 0, 0, 0, 0, utc),
+			want: time.Date(2028, 2, 29, 0, 0, 0, 0, utc),
+		},
+		{
+			// 2024-03-10 is a sunday
+			name: "day of week only",
+			spec: "0 0 * * 1",
+			from: time.Date(2024, 3, 10, 12, 0, 0, 0, utc),
+			want: time.Date(2024, 3, 11, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "day of month only",
+			spec: "0 0 15 * *",
+			from: time.Date(2024, 3, 10, 12, 0, 0, 0, utc),
+			want: time.Date(2024, 3, 15, 0, 0, 0, 0, utc),
+		},
+		{
+			// when both are restricted, a day matching one of them is valid
+			name: "day of month or day of week",
+			spec: "0 0 15 * 3",
+			from: time.Date(2024, 3, 10, 12, 0, 0, 0, utc),
+			want: time.Date(2024, 3, 13, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "day of month or day of week, day of month first",
+			spec: "0 0 11 * 3",
+			from: time.Date(2024, 3, 10, 12, 0, 0, 0, utc),
+			want: time.Date(2024, 3, 11, 0, 0, 0, 0, utc),
+		},
+		{
+			// a starred day of month with a step is not a restriction
+			name: "starred day of month with day of week",
+			spec: "0 0 */1 * 3",
+			from: time.Date(2024, 3, 10, 12, 0, 0, 0, utc),
+			want: time.Date(2024, 3, 13, 0, 0, 0, 0, utc),
+		},
+		{
+			name: "every",
+			spec: "@every 90s",
+			from: time.Date(2024, 3, 10, 12, 0, 0, 500, utc),
+			want: time.Date(2024, 3, 10, 12, 1, 30, 0, utc),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := Parse(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(test.from); !got.Equal(test.want) {
+				t.Errorf("schedule '%s' from %s: expected %s, got %s", test.spec, test.from, test.want, got)
+			}
+		})
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("timezone database not available: %v", err)
+	}
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{
+			// 2024-03-31 02:00 does not exist in Paris, clocks go from 02:00 to 03:00 and run is skipped that day
+			name: "hour skipped by spring forward",
+			spec: "30 2 * * *",
+			from: time.Date(2024, 3, 30, 12, 0, 0, 0, loc),
+			want: time.Date(2024, 4, 1, 2, 30, 0, 0, loc),
+		},
+		{
+			name: "hour after spring forward",
+			spec: "0 3 * * *",
+			from: time.Date(2024, 3, 31, 1, 0, 0, 0, loc),
+			want: time.Date(2024, 3, 31, 3, 0, 0, 0, loc),
+		},
+		{
+			name: "daily across fall back",
+			spec: "0 12 * * *",
+			from: time.Date(2024, 10, 26, 13, 0, 0, 0, loc),
+			want: time.Date(2024, 10, 27, 12, 0, 0, 0, loc),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := Parse(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(test.from); !got.Equal(test.want) {
+				t.Errorf("schedule '%s' from %s: expected %s, got %s", test.spec, test.from, test.want, got)
+			}
+		})
+	}
+}
+
+func TestNextDSTFallBackRunsOnce(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("timezone database not available: %v", err)
+	}
+	s, err := Parse("30 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-10-27 02:00 happens twice in Paris, schedule must be reached only once that day
+	next := s.Next(time.Date(2024, 10, 27, 0, 0, 0, 0, loc))
+	if next.Day() != 27 || next.Hour() != 2 || next.Minute() != 30 {
+		t.Fatalf("expected a run on 2024-10-27 at 02:30, got %s", next)
+	}
+	if after := s.Next(next); after.Day() != 28 || after.Hour() != 2 || after.Minute() != 30 {
+		t.Errorf("expected next run on 2024-10-28 at 02:30, got %s", after)
+	}
+}
+
+func seq(start, end, step int) []int {
+	values := make([]int, 0)
+	for v := start; v <= end; v += step {
+		values = append(values, v)
+	}
+	return values
+}
+
+func sameValues(values map[int]bool, expected []int) bool {
+	if len(values) != len(expected) {
+		return false
+	}
+	for _, v := range expected {
+		if !values[v] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package sidecars
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDiagnosticLines = 20
+	DefaultStartupWindow   = 10 * time.Second
+
+	// diagnosticInterval is min time between two diagnostics of a process which is restarted,
+	// diagnostic of a process which will not be restarted is always logged
+	diagnosticInterval = 1 * time.Minute
+)
+
+// outputTail keep last lines written by a process on its stdout and stderr
+type outputTail struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int
+	full    bool
+	writers []*tailWriter
+}
+
+func newOutputTail(max int) *outputTail {
+	if max <= 0 {
+		max = DefaultDiagnosticLines
+	}
+	return &outputTail{
+		lines: make([]string, max),
+	}
+}
+
+// writer give a writer which add each line written in tail, each stream must have its own writer
+// to not mix partial lines of different streams
+func (t *outputTail) writer() io.Writer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := &tailWriter{tail: t}
+	t.writers = append(t.writers, w)
+	return w
+}
+
+// add must be called with lock held
+func (t *outputTail) add(line string) {
+	t.lines[t.next] = line
+	t.next = (t.next + 1) % len(t.lines)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// Lines give last lines written, lines not yet ended are given last
+func (t *outputTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := make([]string, 0, len(t.lines))
+	if t.full {
+		lines = append(lines, t.lines[t.next:]...)
+	}
+	lines = append(lines, t.lines[:t.next]...)
+	for _, w := range t.writers {
+		if len(w.partial) > 0 {
+			lines = append(lines, string(w.partial))
+		}
+	}
+	if len(lines) > len(t.lines) {
+		lines = lines[len(lines)-len(t.lines):]
+	}
+	return lines
+}
+
+type tailWriter struct {
+	tail    *outputTail
+	partial []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.tail.mu.Lock()
+	defer w.tail.mu.Unlock()
+	data := p
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		w.partial = append(w.partial, data[:i]...)
+		w.tail.add(strings.TrimSuffix(string(w.partial), "\r"))
+		w.partial = w.partial[:0]
+		data = data[i+1:]
+	}
+	w.partial = append(w.partial, data...)
+	// long lines are cut as in launcher output to show same lines
+	for len(w.partial) >= maxLogLine {
+		w.tail.add(string(w.partial[:maxLogLine]))
+		w.partial = append(w.partial[:0], w.partial[maxLogLine:]...)
+	}
+	return len(p), nil
+}
+
+// logDiagnostic log exit code, signal, runtime, executable and last lines of output of a process
+// which exited unexpectedly
+func (p *process) logDiagnostic(err error) {
+	status := p.status()
+	p.mu.Lock()
+	output := p.output
+	executable := p.cmd.Path
+	p.mu.Unlock()
+	if p.sidecar != nil {
+		executable = SidecarExecPath(p.factory.wd, p.sidecar)
+	}
+	exitCode := "-"
+	if status.ExitCode >= 0 {
+		exitCode = fmt.Sprintf("%d", status.ExitCode)
+	}
+	signalName := "-"
+	if status.ExitSignal != "" {
+		signalName = status.ExitSignal
+	}
+	runtime := status.Runtime().Round(time.Millisecond)
+	reason := "exited unexpectedly"
+	if err != nil {
+		reason = fmt.Sprintf("exited unexpectedly: %s", err.Error())
+	}
+	// init and scheduled sidecars are expected to exit, startup window only make sense for services
+	isService := p.sidecar == nil || (!p.sidecar.IsInit() && !p.sidecar.IsScheduled())
+	if isService && runtime < p.factory.startupWindow {
+		reason += fmt.Sprintf(" (within startup window of %s)", p.factory.startupWindow)
+	}
+	lines := make([]string, 0)
+	if output != nil {
+		lines = output.tail.Lines()
+	}
+	// tail keeps raw output, secrets are masked before lines are logged
+	for i, line := range lines {
+		lines[i] = string(p.factory.redactor.redact([]byte(line)))
+	}
+	entry := log.WithField(p.typeP, p.name).
+		WithField("exit_code", status.ExitCode).
+		WithField("signal", status.ExitSignal).
+		WithField("runtime", runtime.String()).
+		WithField("executable", executable)
+	// with json logs last lines are given as a field, otherwise each line is logged to stay readable
+	logger := log.StandardLogger()
+	if _, isJson := logger.Formatter.(*log.JSONFormatter); isJson {
+		entry.WithField("last_output", lines).Errorf("%s %s %s", p.typeP, p.name, reason)
+		return
+	}
+	entry.Errorf("%s %s %s (exit code: %s, signal: %s, runtime: %s, executable: %s)",
+		p.typeP, p.name, reason, exitCode, signalName, runtime, executable)
+	if len(lines) == 0 {
+		entry.Errorf("%s %s gave no output", p.typeP, p.name)
+		return
+	}
+	entry.Errorf("Last %d lines of output of %s %s:", len(lines), p.typeP, p.name)
+	lineEntry := log.WithField(p.typeP, p.name)
+	for _, line := range lines {
+		lineEntry.Errorf("| %s", line)
+	}
+}
+
+// diagnosticDue says if diagnostic of a process which will be restarted can be logged,
+// it is logged at most once per diagnostic interval to not flood logs of a process failing in loop
+func (p *process) diagnosticDue() bool {
+	if !p.lastDiagnostic.IsZero() && time.Since(p.lastDiagnostic) < diagnosticInterval {
+		return false
+	}
+	p.lastDiagnostic = time.Now()
+	return true
+}
+
+// unexpectedExit says if diagnostic must be logged for a process which exited by itself:
+// it failed or it stopped during its startup window
+func (p *process) unexpectedExit(err error, runtime time.Duration) bool {
+	return err != nil || runtime < p.factory.startupWindow
+}
@@ -0,0 +1,80 @@
+package sidecars
+
+import (
+	"bytes"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"testing"
+)
+
+func TestOutputTailLongLines(t *testing.T) {
+	tail := newOutputTail(5)
+	w := tail.writer()
+	long := strings.Repeat("a", 200*1024)
+	// long line is written in chunks as a process would do
+	for i := 0; i < len(long); i += 4096 {
+		w.Write([]byte(long[i : i+4096]))
+	}
+	w.Write([]byte("\nnext\n"))
+	lines := tail.Lines()
+	if len(lines) != 2 || lines[0] != long || lines[1] != "next" {
+		t.Fatalf("expected long line to be kept as one line, got %d lines", len(lines))
+	}
+
+	w.Write([]byte(strings.Repeat("b", maxLogLine+10)))
+	lines = tail.Lines()
+	if len(lines[len(lines)-2]) != maxLogLine || lines[len(lines)-1] != strings.Repeat("b", 10) {
+		t.Errorf("expected line over %d bytes to be cut as in output", maxLogLine)
+	}
+}
+
+func TestDiagnosticDue(t *testing.T) {
+	p := &process{}
+	if !p.diagnosticDue() {
+		t.Fatal("expected first diagnostic to be logged")
+	}
+	if p.diagnosticDue() {
+		t.Error("expected diagnostic to be rate limited")
+	}
+	p.lastDiagnostic = p.lastDiagnostic.Add(-diagnosticInterval)
+	if !p.diagnosticDue() {
+		t.Error("expected diagnostic to be logged after interval")
+	}
+}
+
+func TestLogDiagnosticUsesLogger(t *testing.T) {
+	f := newTestFactory(t)
+	p, err := f.FromSidecar(&config.Sidecar{Name: "failing", Executable: "sh", Args: []string{"-c", "echo boom; exit 3"}}, testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := p.run()
+	p.output.flush()
+
+	logger := log.StandardLogger()
+	out, formatter := logger.Out, logger.Formatter
+	defer func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+	}()
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	logger.SetFormatter(&log.TextFormatter{DisableColors: true})
+	p.logDiagnostic(runErr)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !strings.Contains(line, "level=error") || !strings.Contains(line, "sidecar=failing") {
+			t.Errorf("expected each diagnostic line to be a log entry, got: %s", line)
+		}
+	}
+	if !strings.Contains(buf.String(), "| boom") {
+		t.Errorf("expected last output in diagnostic, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.SetFormatter(&log.JSONFormatter{})
+	p.logDiagnostic(runErr)
+	if n := strings.Count(buf.String(), "\n"); n != 1 || !strings.Contains(buf.String(), `"last_output":["boom"]`) {
+		t.Errorf("expected one json entry with last output, got: %s", buf.String())
+	}
+}
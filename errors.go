@@ -17,3 +17,21 @@ func NewSidecarError(s *config.Sidecar, err error) *sidecarError {
 func (e sidecarError) Error() string {
 	return fmt.Sprintf("Error on sidecar %s: %s", e.s.Name, e.err.Error())
 }
+
+type exitError struct {
+	code int
+	err  error
+}
+
+// NewExitError create an error giving exit code that cli must use
+func NewExitError(code int, err error) *exitError {
+	return &exitError{code, err}
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e exitError) ExitCode() int {
+	return e.code
+}
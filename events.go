@@ -0,0 +1,292 @@
+package sidecars
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventDownloadStarted   EventType = "download_started"
+	EventDownloadFinished  EventType = "download_finished"
+	EventProcessStarted    EventType = "process_started"
+	EventProcessReady      EventType = "process_ready"
+	EventProcessExited     EventType = "process_exited"
+	EventProcessRestarted  EventType = "process_restarted"
+	EventSignalReceived    EventType = "signal_received"
+	EventShutdownCompleted EventType = "shutdown_complete"
+)
+
+const (
+	DefaultWebhookTimeout      = 5 * time.Second
+	DefaultWebhookMaxRetries   = 3
+	DefaultWebhookRetryBackoff = 1 * time.Second
+	DefaultWebhookQueueSize    = 100
+
+	// webhookDrainTimeout is max time waited on close for pending events to be sent
+	webhookDrainTimeout = 10 * time.Second
+)
+
+// Event is a lifecycle event of launcher or of one of its processes
+type Event struct {
+	Type        EventType `json:"type"`
+	Time        time.Time `json:"time"`
+	Starter     string    `json:"starter,omitempty"`
+	Process     string    `json:"process,omitempty"`
+	ProcessType string    `json:"process_type,omitempty"`
+	Pid         int       `json:"pid,omitempty"`
+	ExitCode    *int      `json:"exit_code,omitempty"`
+	Signal      string    `json:"signal,omitempty"`
+	Restarts    int       `json:"restarts,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Duration    float64   `json:"duration_seconds,omitempty"`
+	Bytes       int64     `json:"bytes,omitempty"`
+}
+
+func processEvent(eventType EventType, p *process) Event {
+	return Event{
+		Type:        eventType,
+		Process:     p.name,
+		ProcessType: p.typeP,
+	}
+}
+
+type eventSink interface {
+	Send(event Event)
+	Close()
+}
+
+// eventEmitter give events to all configured sinks, sinks must never block emitter
+type eventEmitter struct {
+	starter string
+	sinks   []eventSink
+}
+
+func newEventEmitter(conf *config.Events, baseDir, starter string) *eventEmitter {
+	e := &eventEmitter{
+		starter: starter,
+		sinks:   make([]eventSink, 0),
+	}
+	if conf == nil {
+		return e
+	}
+	if conf.File != "" {
+		path := conf.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		e.sinks = append(e.sinks, newFileEventSink(path))
+	}
+	if conf.Webhook != nil {
+		e.sinks = append(e.sinks, newWebhookEventSink(*conf.Webhook))
+	}
+	return e
+}
+
+func (e *eventEmitter) emit(event Event) {
+	if e == nil || len(e.sinks) == 0 {
+		return
+	}
+	event.Time = time.Now().UTC()
+	event.Starter = e.starter
+	for _, sink := range e.sinks {
+		sink.Send(event)
+	}
+}
+
+// Close flush and close all sinks
+func (e *eventEmitter) Close() {
+	if e == nil {
+		return
+	}
+	for _, sink := range e.sinks {
+		sink.Close()
+	}
+}
+
+// fileEventSink write events as json lines in a file, file is opened on first event
+type fileEventSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func newFileEventSink(path string) *fileEventSink {
+	return &fileEventSink{path: path}
+}
+
+func (s *fileEventSink) Send(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+			log.WithField("component", "Events").Errorf("unable to create directory of events file '%s': %v", s.path, err)
+			return
+		}
+		f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.WithField("component", "Events").Errorf("unable to open events file '%s': %v", s.path, err)
+			return
+		}
+		s.file = f
+	}
+	b, err := json.Marshal(event)
+	if err != nil {
+		log.WithField("component", "Events").Errorf("unable to marshal event %s: %v", event.Type, err)
+		return
+	}
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		log.WithField("component", "Events").Errorf("unable to write event in '%s': %v", s.path, err)
+	}
+}
+
+func (s *fileEventSink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return
+	}
+	if err := s.file.Close(); err != nil {
+		log.WithField("component", "Events").Errorf("unable to close events file '%s': %v", s.path, err)
+	}
+	s.file = nil
+}
+
+// webhookEventSink post each event as json to an url, events are queued and sent in background
+// with retries, events are dropped when queue is full to never block processes
+type webhookEventSink struct {
+	mu           sync.Mutex
+	url          string
+	headers      map[string]string
+	maxRetries   int
+	retryBackoff time.Duration
+	client       *http.Client
+	queue        chan Event
+	started      bool
+	closed       bool
+	done         chan struct{}
+}
+
+func newWebhookEventSink(conf config.Webhook) *webhookEventSink {
+	// 0 disable retries, default is only used when max retries is not set
+	maxRetries := DefaultWebhookMaxRetries
+	if conf.MaxRetries != nil {
+		maxRetries = *conf.MaxRetries
+	}
+	queueSize := conf.QueueSize
+	if queueSize == 0 {
+		queueSize = DefaultWebhookQueueSize
+	}
+	return &webhookEventSink{
+		url:          conf.URL,
+		headers:      conf.Headers,
+		maxRetries:   maxRetries,
+		retryBackoff: conf.RetryBackoff.Value(DefaultWebhookRetryBackoff),
+		client:       &http.Client{Timeout: conf.Timeout.Value(DefaultWebhookTimeout)},
+		queue:        make(chan Event, queueSize),
+		done:         make(chan struct{}),
+	}
+}
+
+func (s *webhookEventSink) Send(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if !s.started {
+		s.started = true
+		go s.run()
+	}
+	select {
+	case s.queue <- event:
+	default:
+		log.WithField("component", "Events").Warnf("Webhook events queue is full, event %s is dropped", event.Type)
+	}
+}
+
+func (s *webhookEventSink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		s.post(event)
+	}
+}
+
+func (s *webhookEventSink) post(event Event) {
+	entry := log.WithField("component", "Events")
+	b, err := json.Marshal(event)
+	if err != nil {
+		entry.Errorf("unable to marshal event %s: %v", event.Type, err)
+		return
+	}
+	backoff := s.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err = s.postOnce(b)
+		if err == nil {
+			return
+		}
+		if attempt >= s.maxRetries {
+			entry.Errorf("unable to send event %s to webhook after %d attempts: %v", event.Type, attempt+1, err)
+			return
+		}
+		entry.Debugf("failed to send event %s to webhook, retrying in %s: %v", event.Type, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *webhookEventSink) postOnce(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close wait for queued events to be sent, pending events are abandoned after a timeout
+func (s *webhookEventSink) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	started := s.started
+	close(s.queue)
+	s.mu.Unlock()
+	if !started {
+		return
+	}
+	select {
+	case <-s.done:
+	case <-time.After(webhookDrainTimeout):
+		log.WithField("component", "Events").Warnf("Events not sent to webhook after %s are abandoned", webhookDrainTimeout)
+	}
+}
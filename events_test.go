@@ -0,0 +1,101 @@
+package sidecars
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// webhookStandIn give a webhook failing first failures requests and counting all requests
+func webhookStandIn(t *testing.T, failures int32, calls *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil || event.Type != EventProcessStarted {
+			t.Errorf("unexpected event %v: %v", event, err)
+		}
+		if n <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebhookEventSinkRetries(t *testing.T) {
+	zero := 0
+	one := 1
+	tests := []struct {
+		name       string
+		maxRetries *int
+		failures   int32
+		calls      int32
+	}{
+		{name: "success", failures: 0, calls: 1},
+		{name: "retried until success", failures: 2, calls: 3},
+		{name: "default retries", failures: 10, calls: DefaultWebhookMaxRetries + 1},
+		{name: "retries disabled", maxRetries: &zero, failures: 10, calls: 1},
+		{name: "one retry", maxRetries: &one, failures: 10, calls: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			server := webhookStandIn(t, test.failures, &calls)
+			sink := newWebhookEventSink(config.Webhook{
+				URL:          server.URL,
+				Headers:      map[string]string{"Authorization": "Bearer token"},
+				MaxRetries:   test.maxRetries,
+				RetryBackoff: "1ms",
+			})
+			sink.Send(Event{Type: EventProcessStarted})
+			sink.Close()
+			if n := atomic.LoadInt32(&calls); n != test.calls {
+				t.Errorf("expected %d requests, got %d", test.calls, n)
+			}
+		})
+	}
+}
+
+func TestFileEventSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "events.jsonl")
+	e := newEventEmitter(&config.Events{File: path}, "", "java")
+	e.emit(Event{Type: EventProcessStarted, Process: "app"})
+	code := 2
+	e.emit(Event{Type: EventProcessExited, Process: "app", ExitCode: &code})
+	e.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events := make([]Event, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid json line '%s': %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Type != EventProcessStarted || events[0].Starter != "java" || events[0].Time.IsZero() {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	if events[1].Type != EventProcessExited || events[1].ExitCode == nil || *events[1].ExitCode != 2 {
+		t.Errorf("unexpected second event: %+v", events[1])
+	}
+}
@@ -3,15 +3,22 @@ package sidecars
 import (
 	"fmt"
 	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"github.com/orange-cloudfoundry/cloud-sidecars/cron"
 	"github.com/orange-cloudfoundry/cloud-sidecars/starter"
 	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	log "github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
+// outputWaitDelay is time waited after a process exited for sub processes still holding its output
+// to close it, output is then closed to not block process exit
+const outputWaitDelay = 5 * time.Second
+
 type CmdHandlerFactory func(*exec.Cmd) (CmdHandler, error)
 
 func NoOpCmdHandlerFactory(cmd *exec.Cmd) (CmdHandler, error) {
@@ -21,12 +28,27 @@ func NoOpCmdHandlerFactory(cmd *exec.Cmd) (CmdHandler, error) {
 type ProcessFactory struct {
 	errChan    chan error
 	signalChan chan os.Signal
+	stopChan   chan struct{}
+	stopOnce   *sync.Once
+	changeChan chan struct{}
 	wg         *sync.WaitGroup
+	wgMu       sync.Mutex
+	wgHeld     int
+	wgUsed     bool
 	wd         string
 	stdout     io.Writer
 	stderr     io.Writer
 	cStarter   starter.Starter
 	cmdFactory CmdHandlerFactory
+
+	diagnosticLines int
+	startupWindow   time.Duration
+	events          *eventEmitter
+	logMux          *logMux
+	logFilesMu      sync.Mutex
+	logFiles        map[string]*rotatingFile
+	syslog          *syslogSink
+	redactor        *redactor
 }
 
 func NewProcessFactory(
@@ -36,12 +58,20 @@ func NewProcessFactory(
 	return &ProcessFactory{
 		errChan:    make(chan error, 100),
 		signalChan: make(chan os.Signal, 100),
+		stopChan:   make(chan struct{}),
+		stopOnce:   &sync.Once{},
+		changeChan: make(chan struct{}, 1),
 		wg:         &sync.WaitGroup{},
 		stderr:     stderr,
 		stdout:     stdout,
 		wd:         wd,
 		cStarter:   cStarter,
 		cmdFactory: NoOpCmdHandlerFactory,
+
+		diagnosticLines: DefaultDiagnosticLines,
+		startupWindow:   DefaultStartupWindow,
+		logMux:          newLogMux(),
+		logFiles:        make(map[string]*rotatingFile),
 	}
 }
 
@@ -49,10 +79,59 @@ func (f *ProcessFactory) SetCmdHandlerFactory(cmdFactory CmdHandlerFactory) {
 	f.cmdFactory = cmdFactory
 }
 
+// SetDiagnostic set number of last output lines kept for each process and time after start
+// during which a process exiting, even successfully, is considered as an unexpected exit
+func (f *ProcessFactory) SetDiagnostic(lines int, startupWindow time.Duration) {
+	if lines > 0 {
+		f.diagnosticLines = lines
+	}
+	f.startupWindow = startupWindow
+}
+
+// SetSyslog set sink to which outputs of all processes are sent
+func (f *ProcessFactory) SetSyslog(syslog *syslogSink) {
+	f.syslog = syslog
+}
+
+// SetRedactor set redactor masking secrets in outputs of all processes
+func (f *ProcessFactory) SetRedactor(redactor *redactor) {
+	f.redactor = redactor
+}
+
+// SetEvents set emitter used by processes to emit their lifecycle events
+func (f *ProcessFactory) SetEvents(events *eventEmitter) {
+	f.events = events
+}
+
 func (f *ProcessFactory) WaitGroup() *sync.WaitGroup {
 	return f.wg
 }
 
+// hold add n running processes to wait group. It fails when wait group was used and all processes
+// finished as launcher may already be waiting on it, it must never be added to when empty and waited.
+func (f *ProcessFactory) hold(n int) bool {
+	if n <= 0 {
+		return true
+	}
+	f.wgMu.Lock()
+	defer f.wgMu.Unlock()
+	if f.wgUsed && f.wgHeld == 0 {
+		return false
+	}
+	f.wgUsed = true
+	f.wgHeld += n
+	f.wg.Add(n)
+	return true
+}
+
+// release remove a finished process from wait group
+func (f *ProcessFactory) release() {
+	f.wgMu.Lock()
+	defer f.wgMu.Unlock()
+	f.wgHeld--
+	f.wg.Done()
+}
+
 func (f *ProcessFactory) ErrorChan() chan error {
 	return f.errChan
 }
@@ -61,16 +140,111 @@ func (f *ProcessFactory) SignalChan() chan os.Signal {
 	return f.signalChan
 }
 
+func (f *ProcessFactory) StopChan() chan struct{} {
+	return f.stopChan
+}
+
+// ChangeChan receive a notification each time state of a process changed
+func (f *ProcessFactory) ChangeChan() chan struct{} {
+	return f.changeChan
+}
+
+// Stop notify all processes that they are stopping, they will not be restarted
+// and their exit will not be considered as an error
+func (f *ProcessFactory) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stopChan)
+	})
+}
+
+// FlushOutput write lines not yet ended of all processes outputs
+func (f *ProcessFactory) FlushOutput() {
+	f.logMux.Flush()
+}
+
+// CloseOutput flush all processes outputs, close log files of sidecars and send pending syslog messages
+func (f *ProcessFactory) CloseOutput() {
+	f.FlushOutput()
+	f.logFilesMu.Lock()
+	for _, logFile := range f.logFiles {
+		logFile.Close()
+	}
+	f.logFilesMu.Unlock()
+	f.syslog.Close()
+}
+
+// logFile give log file of sidecar, it is kept across restarts and reloads of sidecar
+func (f *ProcessFactory) logFile(sidecar *config.Sidecar) *rotatingFile {
+	f.logFilesMu.Lock()
+	defer f.logFilesMu.Unlock()
+	logFile, ok := f.logFiles[sidecar.Name]
+	if ok {
+		logFile.setConf(*sidecar.Log)
+		return logFile
+	}
+	logFile = newRotatingFile(SidecarLogFilePath(f.wd, sidecar.Name), *sidecar.Log)
+	f.logFiles[sidecar.Name] = logFile
+	return logFile
+}
+
+// newOutput give output of app, when sidecar is nil, or of a sidecar. Lines are wrapped in json
+// when format of sidecar is json or, by default, when launcher logs are in json.
+func (f *ProcessFactory) newOutput(sidecar *config.Sidecar) *processOutput {
+	_, isJson := log.StandardLogger().Formatter.(*log.JSONFormatter)
+	name := ""
+	prefix := ""
+	if sidecar != nil {
+		name = sidecar.Name
+		if !sidecar.NoLogPrefix {
+			prefix = fmt.Sprintf("[sidecar:%s]", sidecar.Name)
+		}
+		switch sidecar.LogFormat {
+		case config.LogFormatJson:
+			isJson = true
+		case config.LogFormatText:
+			isJson = false
+		}
+	}
+	stdoutFormat, stderrFormat := textFormat(prefix), textFormat(prefix)
+	if isJson {
+		stdoutFormat, stderrFormat = jsonFormat(name, "stdout"), jsonFormat(name, "stderr")
+	}
+	stdout, stderr := f.stdout, f.stderr
+	if sidecar != nil && sidecar.Log != nil {
+		// both streams are written in log file, console is written first as log file never fails
+		logFile := f.logFile(sidecar)
+		stdout, stderr = logFile, logFile
+		if sidecar.Log.Console {
+			stdout, stderr = io.MultiWriter(f.stdout, logFile), io.MultiWriter(f.stderr, logFile)
+		}
+	}
+	desc := "app"
+	if sidecar != nil {
+		desc = "sidecar " + sidecar.Name
+	}
+	return &processOutput{
+		tail:         newOutputTail(f.diagnosticLines),
+		stdout:       f.logMux.lineWriter(stdout, desc+" stdout", stdoutFormat).withRedactor(f.redactor),
+		stderr:       f.logMux.lineWriter(stderr, desc+" stderr", stderrFormat).withRedactor(f.redactor),
+		syslogStdout: f.syslog.writer(sidecar, "stdout", f.redactor),
+		syslogStderr: f.syslog.writer(sidecar, "stderr", f.redactor),
+	}
+}
+
 func (f *ProcessFactory) FromStarter(env map[string]string, profileDir string) (*process, error) {
+	f.redactor.addEnv(env)
+	output := f.newOutput(nil)
+	stdout, stderr := output.writers()
 	cloudCmd, err := f.cStarter.StartCmd(
 		utils.EnvMapToOsEnv(env),
 		profileDir,
-		f.stdout,
-		f.stderr,
+		stdout,
+		stderr,
 	)
 	if err != nil {
 		return nil, err
 	}
+	cloudCmd.WaitDelay = outputWaitDelay
 	// set pgid for sending signal to child
 	cloudCmd.SysProcAttr = utils.PgidSysProcAttr(cloudCmd.SysProcAttr)
 	cmdHandler, err := f.cmdFactory(cloudCmd)
@@ -80,13 +254,22 @@ func (f *ProcessFactory) FromStarter(env map[string]string, profileDir string) (
 	return &process{
 		cmd:             cloudCmd,
 		cmdHandler:      cmdHandler,
+		output:          output,
 		name:            "launcher",
 		typeP:           "cloud",
 		noInterrupt:     true,
 		alwaysInterrupt: true,
+		restartPolicy:   newRestartPolicy(nil),
+		factory:         f,
 		errChan:         f.errChan,
 		signalChan:      f.signalChan,
-		wg:              f.wg,
+		stopChan:        f.stopChan,
+		quitChan:        make(chan struct{}),
+		startedChan:     make(chan struct{}),
+		startChan:       make(chan struct{}, 1),
+		exitCode:        -1,
+		readyChan:       make(chan struct{}),
+		doneChan:        make(chan struct{}),
 	}, nil
 }
 
@@ -104,38 +287,70 @@ func (f *ProcessFactory) FromSidecar(sidecar *config.Sidecar, env map[string]str
 		return nil, fmt.Errorf("workdir '%s' doesn't exists", wd)
 	}
 
-	args, err := TemplatingArgs(env, sidecar.Args...)
+	// args are copied as templating is done in place and sidecar can be recreated on restart
+	args, err := TemplatingArgs(env, append([]string{}, sidecar.Args...)...)
 	if err != nil {
 		return nil, err
 	}
 	cmd := exec.Command(SidecarExecPath(f.wd, sidecar), args...)
 	cmd.Env = utils.EnvMapToOsEnv(env)
+	f.redactor.addEnv(env)
 	cmd.Dir = wd
 	// set pgid for sending signal to child
 	cmd.SysProcAttr = utils.PgidSysProcAttr(nil)
-	if !sidecar.NoLogPrefix {
-		writerPrefix := fmt.Sprintf("[sidecar:%s]", sidecar.Name)
-		err := PrefixCmdOutput(f.stdout, f.stderr, cmd, writerPrefix)
+	if sidecar.HasCredential() {
+		cmd.SysProcAttr, err = utils.CredentialSysProcAttr(cmd.SysProcAttr, sidecar.User, sidecar.Group, sidecar.Groups)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		cmd.Stdout = f.stdout
-		cmd.Stderr = f.stderr
 	}
+	if err := wrapWithLimits(cmd, sidecar); err != nil {
+		return nil, err
+	}
+	output := f.newOutput(sidecar)
+	cmd.Stdout, cmd.Stderr = output.writers()
+	cmd.WaitDelay = outputWaitDelay
 	cmdHandler, err := f.cmdFactory(cmd)
 	if err != nil {
 		return nil, err
 	}
+	var liveness *prober
+	if sidecar.Liveness != nil {
+		liveness, err = newProber(sidecar.Liveness, env, wd)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var schedule *cron.Schedule
+	if sidecar.IsScheduled() {
+		schedule, err = cron.Parse(sidecar.Schedule)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &process{
-		cmd:         cmd,
-		cmdHandler:  cmdHandler,
-		name:        sidecar.Name,
-		typeP:       "sidecar",
-		noInterrupt: sidecar.NoInterruptWhenStop,
-		errChan:     f.errChan,
-		signalChan:  f.signalChan,
-		wg:          f.wg,
+		cmd:           cmd,
+		cmdHandler:    cmdHandler,
+		output:        output,
+		sidecar:       sidecar,
+		env:           env,
+		wd:            wd,
+		factory:       f,
+		name:          sidecar.Name,
+		typeP:         "sidecar",
+		noInterrupt:   sidecar.NoInterruptWhenStop,
+		restartPolicy: newRestartPolicy(sidecar),
+		liveness:      liveness,
+		schedule:      schedule,
+		errChan:       f.errChan,
+		signalChan:    f.signalChan,
+		stopChan:      f.stopChan,
+		quitChan:      make(chan struct{}),
+		startedChan:   make(chan struct{}),
+		startChan:     make(chan struct{}, 1),
+		exitCode:      -1,
+		readyChan:     make(chan struct{}),
+		doneChan:      make(chan struct{}),
 	}, nil
 }
 
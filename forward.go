@@ -0,0 +1,86 @@
+package sidecars
+
+import (
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// forwardedSignals give signals to forward and names of their target processes, no target means all processes
+func (l Launcher) forwardedSignals() map[os.Signal][]string {
+	forwarded := make(map[os.Signal][]string)
+	for _, forward := range l.sConfig.ForwardSignals {
+		sig, err := utils.ParseSignal(forward.Signal)
+		if err != nil {
+			continue
+		}
+		forwarded[sig] = forward.To
+	}
+	return forwarded
+}
+
+// notifyForwardedSignals make launcher receive signals to forward, it says if SIGHUP is forwarded
+// as it is then not used anymore for reloading configuration
+func (l Launcher) notifyForwardedSignals(forwardChan chan os.Signal) (hupForwarded bool) {
+	forwarded := l.forwardedSignals()
+	if len(forwarded) == 0 {
+		return false
+	}
+	sigs := make([]os.Signal, 0, len(forwarded))
+	for sig := range forwarded {
+		sigs = append(sigs, sig)
+		if sig == syscall.SIGHUP {
+			hupForwarded = true
+		}
+	}
+	signal.Notify(forwardChan, sigs...)
+	return hupForwarded
+}
+
+// handlingForwardSignals forward each signal received to its target processes until launcher is stopping
+func (l Launcher) handlingForwardSignals(forwardChan chan os.Signal) {
+	forwarded := l.forwardedSignals()
+	stopChan := l.processFactory.StopChan()
+	for {
+		var sig os.Signal
+		select {
+		case <-stopChan:
+			return
+		case sig = <-forwardChan:
+		}
+		for _, p := range l.forwardTargets(forwarded[sig]) {
+			entry := log.WithField(p.typeP, p.name)
+			if !p.status().Running {
+				entry.Debugf("%s %s is not running, signal '%s' is not forwarded", p.typeP, p.name, sig)
+				continue
+			}
+			entry.Infof("Forwarding signal '%s' to %s %s", sig, p.typeP, p.name)
+			// signal is sent to process group when process has its own group
+			if err := p.signal(sig); err != nil {
+				entry.Errorf("failed to forward signal '%s' to %s %s: %v", sig, p.typeP, p.name, err)
+			}
+		}
+	}
+}
+
+// forwardTargets give processes having given names, processes are looked in table
+// on each signal as they may have been replaced by a reload
+func (l Launcher) forwardTargets(names []string) []*process {
+	if len(names) == 0 {
+		return l.table.all()
+	}
+	targets := make([]*process, 0, len(names))
+	for _, name := range names {
+		p := l.table.get(name)
+		if p == nil && name == config.ForwardToApp {
+			p = l.table.app()
+		}
+		if p != nil {
+			targets = append(targets, p)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,81 @@
+package sidecars
+
+import (
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestForwardSignalsToTargets(t *testing.T) {
+	dir := t.TempDir()
+	signalsFile := filepath.Join(dir, "signals")
+	// each process write its name and signal received in signals file
+	trapping := func(name string) string {
+		return "trap 'echo " + name + ":USR1 >> " + signalsFile + "' USR1; " +
+			"trap 'echo " + name + ":USR2 >> " + signalsFile + "' USR2; " +
+			"while true; do sleep 0.05; done"
+	}
+	l := NewLauncher(config.Sidecars{
+		Dir: dir,
+		Sidecars: []*config.Sidecar{
+			{Name: "a", Executable: "sh", Args: []string{"-c", trapping("a")}},
+			{Name: "b", Executable: "sh", Args: []string{"-c", trapping("b")}},
+		},
+		ForwardSignals: []config.ForwardSignal{
+			{Signal: "SIGUSR1", To: []string{"a", config.ForwardToApp}},
+			{Signal: "SIGUSR2"},
+		},
+	}, testStarter{command: trapping("app")}, "", io.Discard, io.Discard, 8080)
+	_, processes, err := l.CreateProcesses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.table.set(processes)
+	l.processFactory.hold(len(processes))
+	for _, p := range processes {
+		go p.Start()
+		defer p.remove()
+	}
+	waitFor(t, 5*time.Second, "processes to run", func() bool {
+		for _, p := range processes {
+			if !p.status().Running {
+				return false
+			}
+		}
+		return true
+	})
+	// let shells set their traps
+	time.Sleep(200 * time.Millisecond)
+
+	forwardChan := make(chan os.Signal, 2)
+	go l.handlingForwardSignals(forwardChan)
+	defer l.processFactory.Stop()
+	received := func(sig string) []string {
+		b, _ := os.ReadFile(signalsFile)
+		names := make([]string, 0)
+		for _, line := range strings.Fields(string(b)) {
+			if strings.HasSuffix(line, ":"+sig) {
+				names = append(names, strings.TrimSuffix(line, ":"+sig))
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	forwardChan <- syscall.SIGUSR1
+	waitFor(t, 5*time.Second, "SIGUSR1 to be forwarded", func() bool { return len(received("USR1")) >= 2 })
+	forwardChan <- syscall.SIGUSR2
+	waitFor(t, 5*time.Second, "SIGUSR2 to be forwarded", func() bool { return len(received("USR2")) >= 3 })
+	if got := strings.Join(received("USR1"), ","); got != "a,app" {
+		t.Errorf("expected SIGUSR1 to be only forwarded to a and app, got %s", got)
+	}
+	if got := strings.Join(received("USR2"), ","); got != "a,app,b" {
+		t.Errorf("expected SIGUSR2 to be forwarded to all processes, got %s", got)
+	}
+}
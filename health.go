@@ -0,0 +1,167 @@
+package sidecars
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HealthPortEnvKey  = "SIDECAR_HEALTH_PORT"
+	DefaultHealthPath = "/health"
+
+	healthCheckTimeout = 1 * time.Second
+)
+
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+type healthServer struct {
+	table *processTable
+	bind  string
+	// port is configured port, it is allocated when not set
+	port int
+	// allocated is port given by ports allocator, current is port health server listen on once started
+	allocated int
+	current   int
+	path      string
+	server    *http.Server
+}
+
+// newHealthServer give health server listening on bind address, all interfaces when bind is empty
+func newHealthServer(table *processTable, bind string, port int, path string) *healthServer {
+	if path == "" {
+		path = DefaultHealthPath
+	}
+	s := &healthServer{
+		table: table,
+		bind:  bind,
+		port:  port,
+		path:  path,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+path, s.serveHealth)
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// allocatePort give port to health server through ports allocator, when no port is configured it takes next port
+// after app port as reverse proxies do. Port is kept once health server listen on it.
+func (s *healthServer) allocatePort(
+	ports *portAllocator,
+	sidecars []*config.Sidecar,
+	appPort int,
+	reserved map[int]bool,
+) (int, error) {
+	port, err := ports.allocateHealth(sidecars, appPort, s.port, s.current, reserved, true)
+	if err != nil {
+		return 0, err
+	}
+	s.allocated = port
+	return port, nil
+}
+
+func (s *healthServer) Start() error {
+	port := s.allocated
+	if port == 0 {
+		port = s.port
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.bind, strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("health listen: %s", err.Error())
+	}
+	s.current = port
+	log.WithField("component", "Health").Infof("Health available on http://%s%s", listener.Addr(), s.path)
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithField("component", "Health").Errorf("health server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *healthServer) Stop() {
+	if err := s.server.Close(); err != nil {
+		log.WithField("component", "Health").Errorf("failed to stop health server: %v", err)
+	}
+}
+
+type healthComponent struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Critical bool   `json:"critical"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type healthReport struct {
+	Status     string            `json:"status"`
+	Components []healthComponent `json:"components"`
+}
+
+// report check health of each process, instance is up only when app port accept connections
+// and all critical sidecars are running
+func (s *healthServer) report() healthReport {
+	report := healthReport{
+		Status:     HealthUp,
+		Components: make([]healthComponent, 0),
+	}
+	for _, p := range s.table.all() {
+		if p.sidecar != nil && (p.sidecar.IsInit() || p.sidecar.IsScheduled()) {
+			continue
+		}
+		status := p.status()
+		component := healthComponent{
+			Name:     status.Name,
+			Type:     status.Type,
+			Critical: p.sidecar == nil || p.sidecar.Critical,
+			Status:   HealthUp,
+		}
+		switch {
+		case !status.Running:
+			component.Status = HealthDown
+			component.Reason = fmt.Sprintf("process is %s", status.State)
+		case p.sidecar == nil:
+			if err := checkPort(p.ports["listen"]); err != nil {
+				component.Status = HealthDown
+				component.Reason = err.Error()
+			}
+		}
+		if component.Critical && component.Status == HealthDown {
+			report.Status = HealthDown
+		}
+		report.Components = append(report.Components, component)
+	}
+	return report
+}
+
+func (s *healthServer) serveHealth(w http.ResponseWriter, _ *http.Request) {
+	report := s.report()
+	status := http.StatusOK
+	if report.Status != HealthUp {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func checkPort(port int) error {
+	if port == 0 {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), healthCheckTimeout)
+	if err != nil {
+		return fmt.Errorf("app port %d doesn't accept connections", port)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,29 @@
+package sidecars
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestHealthServerBind(t *testing.T) {
+	_, port, err := net.SplitHostPort(freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	s := newHealthServer(newProcessTable(), "127.0.0.1", p, "")
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	resp, err := http.Get("http://127.0.0.1:" + port + DefaultHealthPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,58 @@
+package sidecars
+
+import (
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func testEntry() *log.Entry {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return log.NewEntry(logger)
+}
+
+// newTestFactory give a process factory discarding outputs of processes
+func newTestFactory(t *testing.T) *ProcessFactory {
+	return NewProcessFactory(io.Discard, io.Discard, nil, t.TempDir())
+}
+
+// testEnv give minimal env to run commands in processes
+func testEnv() map[string]string {
+	return map[string]string{"PATH": os.Getenv("PATH")}
+}
+
+// freeAddr give a local tcp address on which nothing listen
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+// waitFor wait for cond to be true and fail test after timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -21,6 +22,10 @@ const (
 	ProxyAppPortEnvKey = "PROXY_APP_PORT"
 	AppPortEnvKey      = "SIDECAR_APP_PORT"
 	PathSidecarsWd     = ".sidecars"
+
+	DefaultShutdownTimeout = 20 * time.Second
+	// SidecarFailureExitCode is exit code used by launch when a sidecar failure caused the shutdown
+	SidecarFailureExitCode = 70
 )
 
 type Launcher struct {
@@ -32,6 +37,12 @@ type Launcher struct {
 	appPort        int
 	processFactory *ProcessFactory
 	indexer        *Indexer
+	table          *processTable
+	configLoader   func() (*config.Sidecars, error)
+	health         *healthServer
+	events         *eventEmitter
+	ports          *portAllocator
+	redactor       *redactor
 }
 
 func NewLauncher(
@@ -51,6 +62,31 @@ func NewLauncher(
 	if appPort == 0 {
 		appPort = defaultAppPort
 	}
+	table := newProcessTable()
+	var health *healthServer
+	if sConfig.Health != nil && sConfig.Health.Enabled {
+		health = newHealthServer(table, sConfig.Health.Bind, sConfig.Health.Port, sConfig.Health.Path)
+	}
+	starterName := ""
+	if cStarter != nil && !sConfig.NoStarter {
+		starterName = cStarter.Name()
+	}
+	events := newEventEmitter(sConfig.Events, sConfig.Dir, starterName)
+	processFactory := NewProcessFactory(stdout, stderr, cStarter, sConfig.Dir)
+	processFactory.SetEvents(events)
+	if sConfig.Syslog != nil {
+		processFactory.SetSyslog(newSyslogSink(*sConfig.Syslog))
+	}
+	redactor := newRedactor(sConfig.Redact)
+	// secrets of sidecars env are known before processes are created to be masked in all launcher logs
+	for _, sidecar := range sConfig.Sidecars {
+		redactor.addEnv(sidecar.Env)
+		redactor.addEnv(sidecar.AppEnv)
+	}
+	processFactory.SetRedactor(redactor)
+	if sConfig.Diagnostic != nil {
+		processFactory.SetDiagnostic(sConfig.Diagnostic.Lines, sConfig.Diagnostic.StartupWindow.Value(DefaultStartupWindow))
+	}
 	return &Launcher{
 		sConfig:        sConfig,
 		cStarter:       cStarter,
@@ -58,11 +94,27 @@ func NewLauncher(
 		stdout:         stdout,
 		stderr:         stderr,
 		appPort:        appPort,
-		processFactory: NewProcessFactory(stdout, stderr, cStarter, sConfig.Dir),
+		processFactory: processFactory,
 		indexer:        NewIndexer(IndexFilePath(sConfig.Dir)),
+		table:          table,
+		health:         health,
+		events:         events,
+		ports:          newPortAllocator(PortsFilePath(sConfig.Dir)),
+		redactor:       redactor,
 	}
 }
 
+// RedactWriter give a writer masking secrets found by launcher in each line written.
+// Each write must be made of complete lines, e.g. a log entry.
+func (l Launcher) RedactWriter(w io.Writer) io.Writer {
+	return l.redactor.writer(w)
+}
+
+// CloseEvents send pending lifecycle events and close events sinks, it must be called before leaving
+func (l Launcher) CloseEvents() {
+	l.events.Close()
+}
+
 func (l Launcher) ShowSidecarsSha1() error {
 	table := tablewriter.NewWriter(l.stdout)
 	table.SetHeader([]string{"Sidecar Name", "Sha1"})
@@ -128,9 +180,20 @@ func (l Launcher) setupSidecarArtifact(sidecar *config.Sidecar) error {
 }
 
 func (l Launcher) Setup() error {
+	startedAt := time.Now()
+	err := l.setup()
+	if err != nil {
+		return err
+	}
+	l.storeSetupMetrics(func(m *SetupMetrics) {
+		m.SetupDuration = time.Since(startedAt).Seconds()
+	})
+	return nil
+}
+
+func (l Launcher) setup() error {
 	entryG := log.WithField("component", "Launcher").WithField("command", "staging")
 	entryG.Infof("Setup sidecars ...")
-	appEnv := make(map[string]string)
 	err := os.MkdirAll(l.profileDir, 0755)
 	if err != nil {
 		return err
@@ -139,7 +202,20 @@ func (l Launcher) Setup() error {
 	if err != nil {
 		return err
 	}
+	// ports are allocated now to give them to app profile, launch will use same ports
+	chain, err := l.ports.allocateChain(l.sConfig.Sidecars, l.appPort, nil, false)
+	if err != nil {
+		return err
+	}
+	ports, err := l.ports.allocate(l.sConfig.Sidecars, chainPorts(l.appPort, chain), nil, false)
+	if err != nil {
+		return err
+	}
+	appEnv := PortsEnv(ports)
 	appPort := l.appPort
+	if len(chain) > 0 {
+		appPort = chain[len(chain)-1]
+	}
 	for id, sidecar := range l.sConfig.Sidecars {
 		entry := entryG.WithField("sidecar", sidecar.Name)
 		entry.Infof("Setup ...")
@@ -154,9 +230,6 @@ func (l Launcher) Setup() error {
 			return err
 		}
 		appEnv = utils.MergeEnv(appEnv, appEnvUnTpl)
-		if sidecar.IsRproxy {
-			appPort++
-		}
 		if sidecar.ProfileD != "" {
 			fileName := fmt.Sprintf("%d_%s.sh", id+1, sidecar.Name)
 			entry.Infof("Writing profiled file '%s' ...", fileName)
@@ -197,6 +270,23 @@ func (l Launcher) Setup() error {
 }
 
 func (l Launcher) DownloadArtifacts() error {
+	startedAt := time.Now()
+	artifacts := make(map[string]ArtifactMetrics)
+	err := l.downloadArtifacts(artifacts)
+	if err != nil {
+		return err
+	}
+	l.storeSetupMetrics(func(m *SetupMetrics) {
+		m.DownloadArtifactsDuration = time.Since(startedAt).Seconds()
+		for name, artifact := range artifacts {
+			m.Artifacts[name] = artifact
+		}
+	})
+	return nil
+}
+
+// downloadArtifacts download artifacts of sidecars and give download duration and size of each downloaded artifact
+func (l Launcher) downloadArtifacts(artifacts map[string]ArtifactMetrics) error {
 	entryG := log.WithField("component", "Launcher").WithField("command", "download_artifact")
 	entryG.Info("Start downloading artifacts from sidecars ...")
 	for _, sidecar := range l.sConfig.Sidecars {
@@ -214,7 +304,8 @@ func (l Launcher) DownloadArtifacts() error {
 			continue
 		}
 		dir := SidecarDir(l.sConfig.Dir, sidecar.Name)
-		if err := os.RemoveAll(dir); err != nil {
+		// logs of sidecar are kept when its artifact is downloaded again
+		if err := removeSidecarFiles(dir); err != nil {
 			log.Errorf("unable to remove all '%s': %v", dir, err)
 		}
 		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
@@ -222,9 +313,23 @@ func (l Launcher) DownloadArtifacts() error {
 		}
 		zipFileName := sidecar.Name + ".zip"
 		zipFilePath := filepath.Join(dir, zipFileName)
+		downloadStartedAt := time.Now()
+		l.events.emit(Event{Type: EventDownloadStarted, Process: sidecar.Name, ProcessType: "sidecar"})
+		finished := Event{Type: EventDownloadFinished, Process: sidecar.Name, ProcessType: "sidecar"}
 		if err := DownloadSidecar(zipFilePath, sidecar); err != nil {
+			finished.Duration = time.Since(downloadStartedAt).Seconds()
+			finished.Error = err.Error()
+			l.events.emit(finished)
 			return NewSidecarError(sidecar, err)
 		}
+		artifact := ArtifactMetrics{DownloadDuration: time.Since(downloadStartedAt).Seconds()}
+		if fi, err := os.Stat(zipFilePath); err == nil {
+			artifact.DownloadedBytes = fi.Size()
+		}
+		artifacts[sidecar.Name] = artifact
+		finished.Duration = artifact.DownloadDuration
+		finished.Bytes = artifact.DownloadedBytes
+		l.events.emit(finished)
 
 		if err := l.indexer.UpdateOrCreateIndex(sidecar, filepath.Join(PathSidecarsWd, sidecar.Name, zipFileName)); err != nil {
 			log.Errorf("unable to update or create index for sidecar '%s': %v", sidecar.Name, err)
@@ -256,6 +361,28 @@ func (l Launcher) DownloadArtifacts() error {
 }
 
 func (l Launcher) Launch() error {
+	// launcher logs are serialized with processes outputs to never be mixed with a line of a process
+	logger := log.StandardLogger()
+	logOut := logger.Out
+	logger.SetOutput(l.processFactory.logMux.writer(logOut))
+	err := l.launch()
+	l.processFactory.CloseOutput()
+	logger.SetOutput(logOut)
+	event := Event{Type: EventShutdownCompleted}
+	exitCode := 0
+	if err != nil {
+		exitCode = 1
+		event.Error = err.Error()
+		if errExit, ok := err.(*exitError); ok {
+			exitCode = errExit.ExitCode()
+		}
+	}
+	event.ExitCode = &exitCode
+	l.events.emit(event)
+	return err
+}
+
+func (l Launcher) launch() error {
 	entry := log.WithField("component", "Launcher").
 		WithField("command", "launch")
 
@@ -267,127 +394,555 @@ func (l Launcher) Launch() error {
 	}
 	entry.Info("Finished creating all processes ...")
 
-	wg.Add(processLen)
 	pProcesses := &processes
+	l.table.set(processes)
 
 	signalChan := l.processFactory.SignalChan()
 	errChan := l.processFactory.ErrorChan()
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
+	starterName := ""
+	if l.cStarter != nil && !l.sConfig.NoStarter {
+		starterName = l.cStarter.Name()
+	}
+	stateDone := make(chan struct{})
+	stateFinished := make(chan struct{})
+	stateWriter := newStateWriter(StateFilePath(l.sConfig.Dir), l.table, l.processFactory.ChangeChan(), starterName)
+	go func() {
+		stateWriter.Run(stateDone)
+		close(stateFinished)
+	}()
+	// last state is written before leaving to let status command know that launcher finished
+	defer func() {
+		close(stateDone)
+		<-stateFinished
+	}()
+
+	if l.sConfig.Control != nil && l.sConfig.Control.Enabled {
+		control := newControlServer(
+			l.table,
+			signalChan,
+			ControlSocketPath(l.sConfig.Dir, l.sConfig.Control),
+			l.sConfig.Control.Listen,
+		)
+		if err := control.Start(); err != nil {
+			return err
+		}
+		defer control.Stop()
+	}
+
+	if l.sConfig.Metrics != nil && l.sConfig.Metrics.Enabled {
+		listen := l.sConfig.Metrics.Listen
+		if listen == "" {
+			listen = DefaultMetricsListen
+		}
+		metrics := newMetricsServer(l.table, starterName, SetupMetricsFilePath(l.sConfig.Dir), listen)
+		if err := metrics.Start(); err != nil {
+			return err
+		}
+		defer metrics.Stop()
+	}
+
+	if l.health != nil {
+		if err := l.health.Start(); err != nil {
+			return err
+		}
+		defer l.health.Stop()
+	}
+
+	// orphaned processes are adopted by launcher when it is PID 1 or a subreaper, they must be reaped
+	reap := os.Getpid() == 1
+	if l.sConfig.Subreaper && !reap {
+		if err := setSubreaper(); err != nil {
+			entry.Warnf("Unable to act as subreaper, orphaned processes will not be reaped by launcher: %s", err.Error())
+		} else {
+			reap = true
+		}
+	}
+	if reap {
+		reaperDone := make(chan struct{})
+		go runReaper(reaperDone)
+		defer close(reaperDone)
+	}
+
 	// manage graceful shutdown
 	go l.handlingSignal(pProcesses, processLen, signalChan)
 
-	for _, p := range processes {
-		go p.Start()
+	forwardChan := make(chan os.Signal, 10)
+	hupForwarded := l.notifyForwardedSignals(forwardChan)
+	defer signal.Stop(forwardChan)
+	go l.handlingForwardSignals(forwardChan)
+
+	if hupForwarded && l.configLoader != nil {
+		entry.Warn("SIGHUP is forwarded to processes, configuration will not be reloaded on SIGHUP")
+	}
+	if l.configLoader != nil && !hupForwarded {
+		reloadChan := make(chan os.Signal, 1)
+		signal.Notify(reloadChan, syscall.SIGHUP)
+		defer signal.Stop(reloadChan)
+		go l.handlingReload(reloadChan)
+	}
+
+	err = l.runInitProcesses(processes)
+	if err != nil {
+		// stop now to not start others processes
+		l.processFactory.Stop()
+		signalChan <- syscall.SIGINT
+	}
+	errStart := l.startProcesses(processes)
+	if err == nil && errStart != nil {
+		err = errStart
+		signalChan <- syscall.SIGINT
 	}
 	wg.Wait()
+	l.ShowExitSummary(l.table.all())
+	// a sidecar failure may be the reason why processes could not be started
 	select {
-	case err = <-errChan:
-		return err
+	case errProcess := <-errChan:
+		return NewExitError(SidecarFailureExitCode, errProcess)
 	default:
+	}
+	if err != nil {
+		return err
+	}
+	if l.sConfig.NoStarter {
+		return nil
+	}
+	// exit with app exit code to let platform know that app crashed
+	app := l.table.app()
+	if app == nil {
 		return nil
 	}
+	status := app.status()
+	if status.ExitCode > 0 {
+		return NewExitError(status.ExitCode, fmt.Errorf("app exited with code %d", status.ExitCode))
+	}
+	return nil
 }
 
-func (l Launcher) CreateProcesses() (processLen int, processes []*process, err error) {
-	processLen = len(l.sConfig.Sidecars)
-	if !l.sConfig.NoStarter {
-		processLen++
+// ShowExitSummary show how each process exited
+func (l Launcher) ShowExitSummary(processes []*process) {
+	table := tablewriter.NewWriter(l.stdout)
+	table.SetHeader([]string{"Process", "Type", "Exit Code", "Signal", "Runtime", "Restarts"})
+	for _, p := range processes {
+		status := p.status()
+		if !status.HasRun() {
+			table.Append([]string{status.Name, status.Type, "-", "-", "-", "-"})
+			continue
+		}
+		exitCode := "-"
+		if status.ExitCode >= 0 {
+			exitCode = strconv.Itoa(status.ExitCode)
+		}
+		signalName := "-"
+		if status.ExitSignal != "" {
+			signalName = status.ExitSignal
+		}
+		table.Append([]string{
+			status.Name,
+			status.Type,
+			exitCode,
+			signalName,
+			status.Runtime().Round(time.Millisecond).String(),
+			strconv.Itoa(status.Restarts),
+		})
 	}
-	processes = make([]*process, processLen)
+	table.Render()
+}
 
-	appEnv := utils.OsEnvToMap()
-	i := 0
-	appPort := l.appPort
+// runInitProcesses run sequentially all init sidecars, it stops on first init sidecar which failed
+func (l Launcher) runInitProcesses(processes []*process) error {
+	var err error
+	for _, p := range processes {
+		if p.sidecar == nil || !p.sidecar.IsInit() {
+			continue
+		}
+		if err != nil || p.isStopping() {
+			close(p.doneChan)
+			p.notifyChange()
+			continue
+		}
+		err = p.RunOnce()
+	}
+	return err
+}
+
+// startProcesses start each process when processes it depends on are started and ready.
+func (l Launcher) startProcesses(processes []*process) error {
+	errChan := l.startProcessesAsync(processes, processes)
+	var err error
+	for errStart := range errChan {
+		if errStart != nil && err == nil {
+			err = errStart
+		}
+	}
+	return err
+}
+
+// startProcessesAsync start given processes in background, dependencies are found in all processes.
+// Returned channel receive result of each start and is closed when all processes are started.
+func (l Launcher) startProcessesAsync(toStart, all []*process) chan error {
+	errChan := make(chan error, len(toStart))
+	toRun := make([]*process, 0, len(toStart))
+	for _, p := range toStart {
+		if p.sidecar != nil && p.sidecar.IsInit() {
+			continue
+		}
+		toRun = append(toRun, p)
+	}
+	// all processes are added to wait group before starting any to never wait on an empty wait group
+	if !l.processFactory.hold(len(toRun)) {
+		errChan <- fmt.Errorf("launcher is stopping")
+		close(errChan)
+		return errChan
+	}
+	startWg := &sync.WaitGroup{}
+	for _, p := range toRun {
+		deps, err := dependencies(p, all)
+		if err != nil {
+			p.cancel()
+			errChan <- err
+			continue
+		}
+		startWg.Add(1)
+		go func(p *process, deps []*process) {
+			defer startWg.Done()
+			errChan <- l.startProcess(p, deps)
+		}(p, deps)
+	}
+	go func() {
+		startWg.Wait()
+		close(errChan)
+	}()
+	return errChan
+}
+
+// dependencies give processes which must be ready before starting process, a dependency without process
+// can never be met. App depends on all reverse proxies and on all sidecars which have a readiness probe.
+func dependencies(p *process, processes []*process) ([]*process, error) {
+	byName := make(map[string]*process)
+	for _, sp := range processes {
+		if sp.sidecar != nil {
+			byName[sp.name] = sp
+		}
+	}
+	deps := make([]*process, 0)
+	if p.sidecar != nil {
+		for _, dep := range p.sidecar.DependsOn {
+			depProcess, ok := byName[dep]
+			if !ok {
+				return nil, fmt.Errorf("%s %s depends on sidecar %s which is not running", p.typeP, p.name, dep)
+			}
+			deps = append(deps, depProcess)
+		}
+		return deps, nil
+	}
+	for _, sp := range processes {
+		if sp.sidecar != nil && (sp.sidecar.IsRproxy || sp.sidecar.Readiness != nil) {
+			deps = append(deps, sp)
+		}
+	}
+	return deps, nil
+}
+
+func (l Launcher) startProcess(p *process, deps []*process) error {
+	entry := log.WithField(p.typeP, p.name)
+	stopChan := l.processFactory.StopChan()
+	for _, dep := range deps {
+		entry.Debugf("Waiting for %s %s to be ready ...", dep.typeP, dep.name)
+		select {
+		case <-dep.readyChan:
+		case <-dep.doneChan:
+			if dep.isReady() {
+				// a finished init sidecar is done and ready
+				continue
+			}
+			p.cancel()
+			return fmt.Errorf("%s %s stopped before %s %s could start", dep.typeP, dep.name, p.typeP, p.name)
+		case <-stopChan:
+			p.cancel()
+			return nil
+		case <-p.quitChan:
+			p.cancel()
+			return nil
+		}
+	}
+	go p.Start()
+	select {
+	case <-p.startedChan:
+	case <-p.doneChan:
+		return nil
+	}
+	if p.sidecar != nil && p.sidecar.Readiness != nil {
+		err := l.waitReady(p)
+		if err != nil {
+			return err
+		}
+	}
+	close(p.readyChan)
+	l.events.emit(processEvent(EventProcessReady, p))
+	return nil
+}
+
+func (l Launcher) waitReady(p *process) error {
+	entry := log.WithField("sidecar", p.name)
+	pr, err := newProber(p.sidecar.Readiness, p.env, p.wd)
+	if err != nil {
+		return NewSidecarError(p.sidecar, err)
+	}
+	entry.Infof("Waiting for sidecar %s to be ready ...", p.name)
+	err = pr.waitReady(l.processFactory.StopChan(), p.doneChan)
+	if err != nil {
+		return NewSidecarError(p.sidecar, err)
+	}
+	entry.Infof("Sidecar %s is ready.", p.name)
+	return nil
+}
+
+// launchEnv is environment computed for each sidecar and for app from sidecars configuration
+type launchEnv struct {
+	sidecarEnvs  map[string]map[string]string
+	sidecarPorts map[string]map[string]int
+	appEnv       map[string]string
+	appPort      int
+}
+
+// buildLaunchEnv compute env of each sidecar and app, sidecars are processed in configuration order
+// to keep reverse proxies chain
+func (l Launcher) buildLaunchEnv(sidecars []*config.Sidecar) (*launchEnv, error) {
+	var err error
+	lEnv := &launchEnv{
+		sidecarEnvs:  make(map[string]map[string]string),
+		sidecarPorts: make(map[string]map[string]int),
+		appEnv:       utils.OsEnvToMap(),
+		appPort:      l.appPort,
+	}
 	if os.Getenv(AppPortEnvKey) != "" {
-		appPort, err = strconv.Atoi(os.Getenv(AppPortEnvKey))
+		lEnv.appPort, err = strconv.Atoi(os.Getenv(AppPortEnvKey))
 		if err != nil {
-			return processLen, processes, err
+			return nil, err
 		}
 	}
-	for _, sidecar := range l.sConfig.Sidecars {
-		env, err := OverrideEnv(utils.OsEnvToMap(), sidecar.Env)
+	firstPort := lEnv.appPort
+	currentPorts := l.currentListenPorts()
+	chain, err := l.ports.allocateChain(sidecars, firstPort, currentPorts, true)
+	if err != nil {
+		return nil, err
+	}
+	reserved := chainPorts(firstPort, chain)
+	healthPort := 0
+	if l.health != nil {
+		lastPort := firstPort
+		if len(chain) > 0 {
+			lastPort = chain[len(chain)-1]
+		}
+		healthPort, err = l.health.allocatePort(l.ports, sidecars, lastPort, reserved)
+		if err != nil {
+			return nil, err
+		}
+		reserved[healthPort] = true
+	}
+	ports, err := l.ports.allocate(sidecars, reserved, l.currentPorts(), true)
+	if err != nil {
+		return nil, err
+	}
+	// declared ports are given to all sidecars and to app
+	portsEnv := PortsEnv(ports)
+	lEnv.appEnv = utils.MergeEnv(lEnv.appEnv, portsEnv)
+	for _, sidecar := range sidecars {
+		env, err := OverrideEnv(utils.MergeEnv(utils.OsEnvToMap(), portsEnv), sidecar.Env)
 		if err != nil {
-			return processLen, processes, NewSidecarError(sidecar, err)
+			return nil, NewSidecarError(sidecar, err)
+		}
+		sidecarPorts := make(map[string]int)
+		for name, port := range ports[sidecar.Name] {
+			sidecarPorts[name] = port
 		}
 		if sidecar.IsRproxy {
 			if l.cStarter != nil && !l.sConfig.NoStarter {
-				env, err = OverrideEnv(env, l.cStarter.ProxyEnv(appPort))
+				env, err = OverrideEnv(env, l.cStarter.ProxyEnv(lEnv.appPort))
 				if err != nil {
-					return processLen, processes, NewSidecarError(sidecar, err)
+					return nil, NewSidecarError(sidecar, err)
 				}
 			}
-			appPort++
+			// port behind reverse proxy is given by allocator, it is next port unless that one is not free
+			sidecarPorts["listen"] = lEnv.appPort
+			sidecarPorts["proxy_app"] = chain[0]
+			lEnv.appPort = chain[0]
+			chain = chain[1:]
 			env, err = OverrideEnv(env, map[string]string{
-				ProxyAppPortEnvKey: fmt.Sprintf("%d", appPort),
+				ProxyAppPortEnvKey: fmt.Sprintf("%d", lEnv.appPort),
 			})
 			if err != nil {
-				return processLen, processes, NewSidecarError(sidecar, err)
+				return nil, NewSidecarError(sidecar, err)
 			}
 		}
-		entry := log.WithField("sidecar", sidecar.Name)
-		entry.Debug("Setup sidecar ...")
-		appEnvUnTpl, err := TemplatingEnv(appEnv, sidecar.AppEnv)
+		appEnvUnTpl, err := TemplatingEnv(lEnv.appEnv, sidecar.AppEnv)
 		if err != nil {
-			return processLen, processes, NewSidecarError(sidecar, err)
+			return nil, NewSidecarError(sidecar, err)
 		}
-		appEnv = utils.MergeEnv(appEnv, appEnvUnTpl)
-		processes[i], err = l.processFactory.FromSidecar(sidecar, env)
-		if err != nil {
-			return processLen, processes, NewSidecarError(sidecar, err)
+		lEnv.appEnv = utils.MergeEnv(lEnv.appEnv, appEnvUnTpl)
+		lEnv.sidecarEnvs[sidecar.Name] = env
+		if len(sidecarPorts) > 0 {
+			lEnv.sidecarPorts[sidecar.Name] = sidecarPorts
 		}
-		i++
+	}
+	if l.cStarter != nil && !l.sConfig.NoStarter && lEnv.appPort != l.appPort {
+		lEnv.appEnv = utils.MergeEnv(lEnv.appEnv, l.cStarter.ProxyEnv(lEnv.appPort))
+	}
+	if l.health != nil {
+		// health port is given to app and reverse proxies to let them route health checks
+		healthEnv := map[string]string{HealthPortEnvKey: strconv.Itoa(healthPort)}
+		lEnv.appEnv = utils.MergeEnv(lEnv.appEnv, healthEnv)
+		for _, sidecar := range sidecars {
+			if sidecar.IsRproxy {
+				lEnv.sidecarEnvs[sidecar.Name] = utils.MergeEnv(lEnv.sidecarEnvs[sidecar.Name], healthEnv)
+			}
+		}
+	}
+	return lEnv, nil
+}
+
+func (l Launcher) createSidecarProcess(sidecar *config.Sidecar, lEnv *launchEnv) (*process, error) {
+	entry := log.WithField("sidecar", sidecar.Name)
+	entry.Debug("Setup sidecar ...")
+	p, err := l.processFactory.FromSidecar(sidecar, lEnv.sidecarEnvs[sidecar.Name])
+	if err != nil {
+		return nil, NewSidecarError(sidecar, err)
+	}
+	p.ports = lEnv.sidecarPorts[sidecar.Name]
+	entry.Debug("Finished setup sidecar.")
+	return p, nil
+}
 
-		entry.Debug("Finished setup sidecar.")
+func (l Launcher) createStarterProcess(lEnv *launchEnv) (*process, error) {
+	entryS := log.WithField("starter", l.cStarter.Name())
+	entryS.Debug("Setup cloud starter ...")
+	p, err := l.processFactory.FromStarter(lEnv.appEnv, l.profileDir)
+	if err != nil {
+		return nil, err
 	}
+	p.ports = map[string]int{"listen": lEnv.appPort}
+	entryS.Debug("Finished setup cloud starter ...")
+	return p, nil
+}
+
+func (l Launcher) CreateProcesses() (processLen int, processes []*process, err error) {
+	processLen = len(l.sConfig.Sidecars)
 	if !l.sConfig.NoStarter {
-		entryS := log.WithField("starter", l.cStarter.Name())
-		if appPort != l.appPort {
-			appEnv = utils.MergeEnv(appEnv, l.cStarter.ProxyEnv(appPort))
+		processLen++
+	}
+	processes = make([]*process, processLen)
+
+	lEnv, err := l.buildLaunchEnv(l.sConfig.Sidecars)
+	if err != nil {
+		return processLen, processes, err
+	}
+	// processes are placed by dependencies order
+	sorted, err := config.SortByDependencies(launchOrder(l.sConfig.Sidecars))
+	if err != nil {
+		return processLen, processes, err
+	}
+	i := 0
+	for _, sidecar := range sorted {
+		processes[i], err = l.createSidecarProcess(sidecar, lEnv)
+		if err != nil {
+			return processLen, processes, err
 		}
-		entryS.Debug("Setup cloud starter ...")
-		processes[i], err = l.processFactory.FromStarter(appEnv, l.profileDir)
+		i++
+	}
+	if !l.sConfig.NoStarter {
+		processes[i], err = l.createStarterProcess(lEnv)
 		if err != nil {
 			return processLen, processes, err
 		}
-		entryS.Debug("Finished setup cloud starter ...")
 	}
 	return processLen, processes, err
 }
 
 func (l Launcher) handlingSignal(pProcesses *[]*process, processLen int, signalChan chan os.Signal) {
 	sig := <-signalChan
+	l.events.emit(Event{Type: EventSignalReceived, Signal: sig.String()})
+	// processes are now stopping, they must not be restarted
+	// and must not show error when they receive signal
+	l.processFactory.Stop()
 	// If signal has been set by other process at init we are waiting
 	// to reach number of process required before sending back signal
 	for !processesNotHaveLen(*pProcesses, processLen) {
 		time.Sleep(10 * time.Millisecond)
 	}
-	for _, process := range *pProcesses {
-		if process.cmd.Process == nil {
-			continue // process is not running (which probably create signal)
+	// processes are stopped in reverse order of start, app is stopped first then reverse proxies
+	// and a sidecar is stopped only when sidecars depending on it are stopped
+	// if processes still doesn't stop after shutdown timeout we force shutdown
+	shutdownTimeout := l.sConfig.ShutdownTimeout.Value(DefaultShutdownTimeout)
+	deadline := time.After(shutdownTimeout)
+	// processes table is used as processes may have been changed by a reload
+	processes := l.table.all()
+	for i := len(processes) - 1; i >= 0; i-- {
+		if !l.stopProcess(processes[i], sig, deadline) {
+			log.Warnf("Processes are still running after %s, killing them ...", shutdownTimeout)
+			for _, process := range processes {
+				if err := process.kill(); err != nil {
+					log.Errorf("failed to kill %s %s: %v", process.typeP, process.name, err)
+				}
+			}
+			return
 		}
-		// resent signal for each process to make them detect
-		// when they receive a signal to not show error
-		signalChan <- sig
-		// if setpgid exist in sysproc, we need to send signal to negative pid (-pid)
-		// this will stop all sub process that one of our sidecars or app has started
-		// we override pid value to let us use process.Process.Signal
-		// instead of non os agnostic syscall funcs
-		if utils.HasPgidSysProcAttr(process.cmd.SysProcAttr) {
-			process.cmd.Process.Pid = -process.cmd.Process.Pid
+	}
+}
+
+// stopProcess send stop signal to process and wait for it to stop, process is killed if it doesn't stop before its
+// stop timeout. It returns false if shutdown deadline has been reached before process stopped.
+func (l Launcher) stopProcess(p *process, received os.Signal, deadline <-chan time.Time) bool {
+	entry := log.WithField(p.typeP, p.name)
+	sig := p.stopSignal(received)
+	entry.Debugf("Stopping %s %s with signal '%s' ...", p.typeP, p.name, sig)
+	if err := p.signal(sig); err != nil {
+		entry.Errorf("failing to send signal '%s' to %s %s: %v", sig, p.typeP, p.name, err)
+	}
+	var timeout <-chan time.Time
+	if stopTimeout := p.stopTimeout(); stopTimeout > 0 {
+		timeout = time.After(stopTimeout)
+	}
+	select {
+	case <-p.doneChan:
+		return true
+	case <-deadline:
+		return false
+	case <-timeout:
+	}
+	entry.Warnf("%s %s is still running after %s, killing it ...", p.typeP, p.name, p.stopTimeout())
+	if err := p.kill(); err != nil {
+		entry.Errorf("failed to kill %s %s: %v", p.typeP, p.name, err)
+	}
+	select {
+	case <-p.doneChan:
+		return true
+	case <-deadline:
+		return false
+	}
+}
+
+// launchOrder place init sidecars first and reverse proxies last to make them started after others sidecars
+// and stopped right after app, in reverse chain order, when dependencies allow it
+func launchOrder(sidecars []*config.Sidecar) []*config.Sidecar {
+	ordered := make([]*config.Sidecar, 0, len(sidecars))
+	for _, sidecar := range sidecars {
+		if sidecar.IsInit() {
+			ordered = append(ordered, sidecar)
 		}
-		if err := process.cmd.Process.Signal(sig); err != nil {
-			log.Errorf("failing to send signal '%s' to process '%d'", sig, process.cmd.Process.Pid)
+	}
+	for _, sidecar := range sidecars {
+		if !sidecar.IsRproxy && !sidecar.IsInit() {
+			ordered = append(ordered, sidecar)
 		}
 	}
-	// if processes still doesn't stop after 20 sec we force shutdown
-	time.Sleep(20 * time.Second)
-	for _, process := range *pProcesses {
-		signalChan <- syscall.SIGKILL
-		if err := process.cmd.Process.Kill(); err != nil {
-			log.Errorf("failed to kill process '%d': %v", process.cmd.Process.Pid, err)
+	for _, sidecar := range sidecars {
+		if sidecar.IsRproxy {
+			ordered = append(ordered, sidecar)
 		}
 	}
+	return ordered
 }
 
 func SidecarDir(baseDir, sidecarName string) string {
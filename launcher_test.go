@@ -0,0 +1,183 @@
+package sidecars
+
+import (
+	"bytes"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testStarter start app as a shell command
+type testStarter struct {
+	command string
+}
+
+func (s testStarter) StartCmd(env []string, _ string, stdout, stderr io.Writer) (*exec.Cmd, error) {
+	cmd := exec.Command("sh", "-c", s.command)
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd, nil
+}
+
+func (testStarter) Name() string {
+	return "test"
+}
+
+func (testStarter) ProxyEnv(_ int) map[string]string {
+	return map[string]string{}
+}
+
+func (testStarter) AppPort() int {
+	return 0
+}
+
+func (testStarter) Detect() bool {
+	return true
+}
+
+// launchInBackground run launch of launcher and give channel receiving its result
+func launchInBackground(l *Launcher) chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- l.Launch()
+	}()
+	return result
+}
+
+func waitLaunch(t *testing.T, result chan error) error {
+	t.Helper()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(20 * time.Second):
+		t.Fatal("timeout waiting for launch to finish")
+		return nil
+	}
+}
+
+func TestLaunchStopInReverseDependencyOrder(t *testing.T) {
+	dir := t.TempDir()
+	stopFile := filepath.Join(dir, "stops")
+	// each sidecar write its name and received signal in stop file when it is stopped
+	trapping := func(name string, signals ...string) []string {
+		traps := make([]string, 0)
+		for _, sig := range signals {
+			traps = append(traps, "trap 'echo "+name+":"+sig+" >> "+stopFile+"; exit 0' "+sig)
+		}
+		return []string{"-c", strings.Join(traps, "; ") + "; while true; do sleep 0.05; done"}
+	}
+	l := NewLauncher(config.Sidecars{
+		NoStarter: true,
+		Dir:       dir,
+		Sidecars: []*config.Sidecar{
+			{Name: "db", Executable: "sh", Args: trapping("db", "TERM")},
+			{Name: "api", Executable: "sh", Args: trapping("api", "TERM", "USR1"), StopSignal: "SIGUSR1", DependsOn: []string{"db"}},
+			{Name: "front", Executable: "sh", Args: trapping("front", "TERM"), DependsOn: []string{"api"}},
+			// ignore stop signal, it must be killed after its stop timeout
+			{Name: "stubborn", Executable: "sh", Args: []string{"-c", "trap '' TERM; while true; do sleep 0.05; done"}, StopTimeout: "300ms", DependsOn: []string{"db"}},
+		},
+	}, nil, "", io.Discard, io.Discard, 8080)
+	result := launchInBackground(l)
+	waitFor(t, 10*time.Second, "sidecars to run", func() bool {
+		processes := l.table.all()
+		for _, p := range processes {
+			if !p.status().Running {
+				return false
+			}
+		}
+		return len(processes) == 4
+	})
+	// let shells set their traps
+	time.Sleep(200 * time.Millisecond)
+	stopAsked := time.Now()
+	l.processFactory.SignalChan() <- syscall.SIGTERM
+	if err := waitLaunch(t, result); err != nil {
+		t.Fatalf("unexpected launch error: %v", err)
+	}
+
+	b, err := os.ReadFile(stopFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stops := strings.Fields(string(b))
+	expected := []string{"front:TERM", "api:USR1", "db:TERM"}
+	if strings.Join(stops, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected dependents to be stopped first with their stop signal %v, got %v", expected, stops)
+	}
+	stubborn := l.table.get("stubborn").status()
+	if stubborn.ExitCode != 128+int(syscall.SIGKILL) {
+		t.Errorf("expected sidecar ignoring stop signal to be killed, got exit code %d", stubborn.ExitCode)
+	}
+	if elapsed := time.Since(stopAsked); elapsed < 300*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("expected sidecar to be killed after its stop timeout of 300ms, shutdown took %s", elapsed)
+	}
+}
+
+func TestLaunchReturnsAppExitCode(t *testing.T) {
+	var stdout bytes.Buffer
+	l := NewLauncher(config.Sidecars{
+		Dir: t.TempDir(),
+		Sidecars: []*config.Sidecar{
+			{Name: "db", Executable: "sleep", Args: []string{"30"}},
+		},
+	}, testStarter{command: "sleep 0.2; exit 3"}, "", &stdout, io.Discard, 8080)
+	err := waitLaunch(t, launchInBackground(l))
+	errExit, ok := err.(*exitError)
+	if !ok {
+		t.Fatalf("expected an exit error, got %v", err)
+	}
+	if errExit.ExitCode() != 3 {
+		t.Errorf("expected launch to exit with app exit code 3, got %d", errExit.ExitCode())
+	}
+
+	// summary give exit code of app and signal which stopped sidecar
+	summary := make(map[string][]string)
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(strings.ReplaceAll(line, "|", " "))
+		if len(fields) >= 4 {
+			summary[fields[0]] = fields
+		}
+	}
+	if app := summary["launcher"]; app == nil || app[2] != "3" || app[3] != "-" {
+		t.Errorf("expected exit code 3 of app in summary, got %v", app)
+	}
+	if db := summary["db"]; db == nil || db[2] != "143" || db[3] != "terminated" {
+		t.Errorf("expected sidecar stopped by SIGTERM in summary, got %v", db)
+	}
+}
+
+func TestLaunchInitFailureAbortsBeforeServices(t *testing.T) {
+	dir := t.TempDir()
+	startedFile := filepath.Join(dir, "started")
+	l := NewLauncher(config.Sidecars{
+		Dir: dir,
+		Sidecars: []*config.Sidecar{
+			{Name: "api", Executable: "sh", Args: []string{"-c", "echo api >> " + startedFile + "; sleep 30"}, DependsOn: []string{"db"}},
+			{Name: "db", Executable: "sh", Args: []string{"-c", "echo db >> " + startedFile + "; sleep 30"}},
+			{Name: "migrate", Executable: "sh", Args: []string{"-c", "exit 2"}, Type: config.SidecarTypeInit},
+			{Name: "seed", Executable: "sh", Args: []string{"-c", "echo seed >> " + startedFile}, Type: config.SidecarTypeInit},
+		},
+	}, testStarter{command: "echo app >> " + startedFile + "; sleep 30"}, "", io.Discard, io.Discard, 8080)
+	err := waitLaunch(t, launchInBackground(l))
+	if err == nil {
+		t.Fatal("expected launch to fail when an init sidecar failed")
+	}
+	if b, _ := os.ReadFile(startedFile); len(b) > 0 {
+		t.Errorf("expected no process to be started after init sidecar failed, got %q", string(b))
+	}
+	for _, name := range []string{"api", "db", "seed", "launcher"} {
+		if l.table.get(name).status().HasRun() {
+			t.Errorf("expected %s not to be run", name)
+		}
+	}
+	if !l.table.get("migrate").status().HasRun() {
+		t.Error("expected init sidecar migrate to be run")
+	}
+}
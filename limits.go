@@ -0,0 +1,103 @@
+package sidecars
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// LimitsShimCommand is cli command used to apply limits of a sidecar in its own process
+// before running sidecar executable, it must be registered by cli which use launcher
+const LimitsShimCommand = "exec-with-limits"
+
+// limitsShimRegistered says if binary running launcher has registered limits shim command
+var limitsShimRegistered bool
+
+// RegisterLimitsShim must be called by a binary which register LimitsShimCommand as a command running
+// ExecWithLimits. Launcher run its own binary with this command to apply limits, sidecars with limits
+// fail to start when it is not registered (e.g. launcher embedded in another binary).
+func RegisterLimitsShim() {
+	limitsShimRegistered = true
+}
+
+// limitNames give names of limits which are set
+func limitNames(limits *config.Limits) []string {
+	names := make([]string, 0)
+	if limits.Nofile > 0 {
+		names = append(names, "nofile")
+	}
+	if limits.AS.IsSet() {
+		names = append(names, "as")
+	}
+	if limits.CPU != "" {
+		names = append(names, "cpu")
+	}
+	if limits.Core.IsSet() {
+		names = append(names, "core")
+	}
+	if limits.Nice != nil {
+		names = append(names, "nice")
+	}
+	if limits.OomScoreAdj != nil {
+		names = append(names, "oom_score_adj")
+	}
+	return names
+}
+
+// wrapWithLimits make command run through limits shim which apply limits and then exec sidecar executable,
+// limits not supported on current platform are only warned
+func wrapWithLimits(cmd *exec.Cmd, sidecar *config.Sidecar) error {
+	limits := sidecar.Limits
+	if limits == nil || cmd.Err != nil {
+		// command will fail on start with its own error
+		return nil
+	}
+	entry := log.WithField("sidecar", sidecar.Name)
+	names := limitNames(limits)
+	supported := make([]string, 0, len(names))
+	unsupported := make([]string, 0)
+	for _, name := range names {
+		if limitSupported(name) {
+			supported = append(supported, name)
+			continue
+		}
+		unsupported = append(unsupported, name)
+	}
+	if len(unsupported) > 0 {
+		entry.Warnf("Limits %s of sidecar %s are not supported on this platform, they are ignored",
+			strings.Join(unsupported, ", "), sidecar.Name)
+	}
+	if len(supported) == 0 {
+		return nil
+	}
+	if !limitsShimRegistered {
+		return fmt.Errorf("limits require command '%s' to be registered by launcher binary", LimitsShimCommand)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("unable to find executable to apply limits: %s", err.Error())
+	}
+	b, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+	cmd.Args = append([]string{self, LimitsShimCommand, string(b), cmd.Path}, cmd.Args...)
+	cmd.Path = self
+	return nil
+}
+
+// parseShimArgs give limits, executable path and args (with arg 0) given to limits shim
+func parseShimArgs(args []string) (*config.Limits, string, []string, error) {
+	if len(args) < 3 {
+		return nil, "", nil, fmt.Errorf("usage: %s <limits> <executable> <arg0> [args...]", LimitsShimCommand)
+	}
+	limits := &config.Limits{}
+	if err := json.Unmarshal([]byte(args[0]), limits); err != nil {
+		return nil, "", nil, fmt.Errorf("invalid limits: %s", err.Error())
+	}
+	return limits, args[1], args[2:], nil
+}
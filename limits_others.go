@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+
+package sidecars
+
+import "fmt"
+
+// limitSupported says if limit can be applied, oom_score_adj only exists on linux
+func limitSupported(name string) bool {
+	return name != "oom_score_adj"
+}
+
+func applyOomScoreAdj(_ int) error {
+	return fmt.Errorf("oom_score_adj is only supported on linux")
+}
@@ -0,0 +1,121 @@
+//go:build !windows
+
+package sidecars
+
+import (
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"math"
+	"os"
+	"reflect"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// ExecWithLimits apply limits given by launcher to current process and replace it by sidecar executable,
+// it is run by limits shim command and never returns on success
+func ExecWithLimits(args []string) error {
+	limits, path, argv, err := parseShimArgs(args)
+	if err != nil {
+		return err
+	}
+	// nice value is set on current thread, exec must be done by the same thread
+	runtime.LockOSThread()
+	if err := applyLimits(limits); err != nil {
+		return err
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// rlimit is a soft and a hard limit of a resource
+type rlimit struct {
+	soft uint64
+	hard uint64
+}
+
+func applyLimits(limits *config.Limits) error {
+	rlimits := make(map[int]rlimit)
+	if limits.Nofile > 0 {
+		rlimits[syscall.RLIMIT_NOFILE] = newRlimitValues(uint64(limits.Nofile), uint64(limits.NofileHard))
+	}
+	if limits.AS.IsSet() {
+		rlimits[syscall.RLIMIT_AS] = newRlimitValues(limits.AS.Value(0), limits.ASHard.Value(0))
+	}
+	if limits.CPU != "" {
+		rlimits[syscall.RLIMIT_CPU] = newRlimitValues(cpuSeconds(limits.CPU), cpuSeconds(limits.CPUHard))
+	}
+	if limits.Core.IsSet() {
+		// core hard limit may be 0, it is only used when it is set
+		core := rlimit{soft: limits.Core.Value(0), hard: limits.Core.Value(0)}
+		if limits.CoreHard.IsSet() {
+			core.hard = limits.CoreHard.Value(0)
+		}
+		rlimits[syscall.RLIMIT_CORE] = core
+	}
+	for resource, value := range rlimits {
+		err := syscall.Setrlimit(resource, newRlimit(value))
+		if err != nil {
+			return fmt.Errorf("unable to set %s limit to %d (hard limit %d): %s",
+				rlimitName(resource), value.soft, value.hard, err.Error())
+		}
+	}
+	if limits.Nice != nil {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, *limits.Nice); err != nil {
+			return fmt.Errorf("unable to set nice to %d: %s", *limits.Nice, err.Error())
+		}
+	}
+	if limits.OomScoreAdj != nil && limitSupported("oom_score_adj") {
+		if err := applyOomScoreAdj(*limits.OomScoreAdj); err != nil {
+			return fmt.Errorf("unable to set oom_score_adj to %d: %s", *limits.OomScoreAdj, err.Error())
+		}
+	}
+	return nil
+}
+
+// newRlimitValues give limit with hard limit equal to soft limit when hard limit is not set (0)
+func newRlimitValues(soft, hard uint64) rlimit {
+	if hard == 0 {
+		hard = soft
+	}
+	return rlimit{soft: soft, hard: hard}
+}
+
+// cpuSeconds give cpu limit in seconds, it is rounded up to never be lower than configured
+func cpuSeconds(cpu config.Duration) uint64 {
+	return uint64((cpu.Value(0) + time.Second - 1) / time.Second)
+}
+
+// newRlimit create syscall rlimit, reflection is used as type of limits differs between platforms
+func newRlimit(value rlimit) *syscall.Rlimit {
+	rl := &syscall.Rlimit{}
+	val := reflect.ValueOf(rl).Elem()
+	for name, v := range map[string]uint64{"Cur": value.soft, "Max": value.hard} {
+		field := val.FieldByName(name)
+		switch field.Kind() {
+		case reflect.Int64:
+			if v > math.MaxInt64 {
+				field.SetInt(math.MaxInt64)
+				continue
+			}
+			field.SetInt(int64(v))
+		case reflect.Uint64:
+			field.SetUint(v)
+		}
+	}
+	return rl
+}
+
+func rlimitName(resource int) string {
+	switch resource {
+	case syscall.RLIMIT_NOFILE:
+		return "nofile"
+	case syscall.RLIMIT_AS:
+		return "as"
+	case syscall.RLIMIT_CPU:
+		return "cpu"
+	case syscall.RLIMIT_CORE:
+		return "core"
+	}
+	return fmt.Sprintf("%d", resource)
+}
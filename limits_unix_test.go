@@ -0,0 +1,59 @@
+//go:build !windows
+
+package sidecars
+
+import (
+	"bytes"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestMain let test binary act as limits shim as launcher binary does
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == LimitsShimCommand {
+		if err := ExecWithLimits(os.Args[2:]); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+func TestLimitsShimNotRegistered(t *testing.T) {
+	limitsShimRegistered = false
+	f := newTestFactory(t)
+	_, err := f.FromSidecar(&config.Sidecar{
+		Name:       "limited",
+		Executable: "sh",
+		Limits:     &config.Limits{Nofile: 256},
+	}, testEnv())
+	if err == nil || !strings.Contains(err.Error(), LimitsShimCommand) {
+		t.Errorf("expected an error about limits shim not registered, got %v", err)
+	}
+}
+
+func TestLimitsSoftAndHard(t *testing.T) {
+	RegisterLimitsShim()
+	defer func() { limitsShimRegistered = false }()
+	out := &bytes.Buffer{}
+	f := NewProcessFactory(out, out, nil, t.TempDir())
+	p, err := f.FromSidecar(&config.Sidecar{
+		Name:        "limited",
+		Executable:  "sh",
+		Args:        []string{"-c", "ulimit -Sn; ulimit -Hn"},
+		Limits:      &config.Limits{Nofile: 256, NofileHard: 512},
+		NoLogPrefix: true,
+	}, testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.run(); err != nil {
+		t.Fatalf("sidecar failed: %v (output: %s)", err, out.String())
+	}
+	p.output.flush()
+	if !strings.Contains(out.String(), "256\n512\n") {
+		t.Errorf("expected soft and hard limits to be applied, got: %s", out.String())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package sidecars
+
+import "fmt"
+
+// limitSupported says if limit can be applied, no limit is supported on windows
+func limitSupported(_ string) bool {
+	return false
+}
+
+func ExecWithLimits(_ []string) error {
+	return fmt.Errorf("limits are not supported on windows")
+}
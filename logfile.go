@@ -0,0 +1,240 @@
+package sidecars
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLogMaxSize  = 10 * 1024 * 1024
+	DefaultLogMaxFiles = 5
+
+	PathSidecarLogs = "logs"
+
+	// logCheckInterval is interval between checks that log file has not been moved or removed
+	logCheckInterval = time.Second
+	logTimeFormat    = "20060102T150405.000"
+)
+
+func SidecarLogDir(baseDir, sidecarName string) string {
+	return filepath.Join(SidecarDir(baseDir, sidecarName), PathSidecarLogs)
+}
+
+func SidecarLogFilePath(baseDir, sidecarName string) string {
+	return filepath.Join(SidecarLogDir(baseDir, sidecarName), sidecarName+".log")
+}
+
+// removeSidecarFiles remove all files of a sidecar directory except its logs
+func removeSidecarFiles(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == PathSidecarLogs && entry.IsDir() {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotatingFile is a log file rotated when it reaches its max size or its max age, rotated files
+// are optionally compressed and only last max files are kept.
+// File is reopened when it has been moved or removed, e.g. by an external log rotation, without any signal.
+type rotatingFile struct {
+	mu        sync.Mutex
+	path      string
+	conf      config.SidecarLog
+	file      *os.File
+	info      os.FileInfo
+	size      int64
+	openedAt  time.Time
+	checkedAt time.Time
+	failing   bool
+
+	cleanMu sync.Mutex
+	cleanWg sync.WaitGroup
+}
+
+func newRotatingFile(path string, conf config.SidecarLog) *rotatingFile {
+	return &rotatingFile{
+		path: path,
+		conf: conf,
+	}
+}
+
+// setConf change rotation configuration, it is taken into account on next write
+func (f *rotatingFile) setConf(conf config.SidecarLog) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conf = conf
+}
+
+// Write never fails to not stop other outputs of process, errors are logged once until writes succeed again
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.write(p)
+	if err != nil && !f.failing {
+		// logs are serialized with processes outputs, they can't be written while writing an output
+		go log.WithField("component", "LogFile").Errorf("unable to write in log file '%s': %v", f.path, err)
+	}
+	f.failing = err != nil
+	return len(p), nil
+}
+
+func (f *rotatingFile) write(p []byte) error {
+	now := time.Now()
+	if f.file != nil && now.Sub(f.checkedAt) >= logCheckInterval {
+		f.checkedAt = now
+		info, err := os.Stat(f.path)
+		if err != nil || !os.SameFile(info, f.info) {
+			f.closeFile()
+		}
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	maxAge := f.conf.MaxAge.Value(0)
+	if f.size > 0 && (f.size+int64(len(p)) > int64(f.conf.MaxSize.Value(DefaultLogMaxSize)) ||
+		(maxAge > 0 && now.Sub(f.openedAt) >= maxAge)) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return err
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.info = info
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	f.checkedAt = f.openedAt
+	return nil
+}
+
+func (f *rotatingFile) closeFile() {
+	if f.file == nil {
+		return
+	}
+	f.file.Close()
+	f.file = nil
+}
+
+// rotate move current file aside with its rotation time and open a new one,
+// compression and removal of old files are made in background
+func (f *rotatingFile) rotate() error {
+	f.closeFile()
+	ext := filepath.Ext(f.path)
+	rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.path, ext), time.Now().UTC().Format(logTimeFormat), ext)
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	conf := f.conf
+	f.cleanWg.Add(1)
+	go func() {
+		defer f.cleanWg.Done()
+		f.clean(rotated, conf)
+	}()
+	return f.open()
+}
+
+// clean compress rotated file if asked and remove oldest rotated files
+func (f *rotatingFile) clean(rotated string, conf config.SidecarLog) {
+	f.cleanMu.Lock()
+	defer f.cleanMu.Unlock()
+	entry := log.WithField("component", "LogFile")
+	if conf.Compress {
+		if err := gzipFile(rotated); err != nil {
+			entry.Errorf("unable to compress log file '%s': %v", rotated, err)
+		}
+	}
+	maxFiles := conf.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = DefaultLogMaxFiles
+	}
+	ext := filepath.Ext(f.path)
+	files, err := filepath.Glob(strings.TrimSuffix(f.path, ext) + "-*" + ext + "*")
+	if err != nil {
+		entry.Errorf("unable to list rotated log files of '%s': %v", f.path, err)
+		return
+	}
+	// rotation time in name make names sorted from oldest to newest
+	sort.Strings(files)
+	for len(files) > maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			entry.Errorf("unable to remove old log file '%s': %v", files[0], err)
+		}
+		files = files[1:]
+	}
+}
+
+// Close close file and wait for rotated files to be cleaned
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	f.closeFile()
+	f.mu.Unlock()
+	f.cleanWg.Wait()
+	return nil
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,134 @@
+package sidecars
+
+import (
+	"compress/gzip"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rotatedFiles give rotated files of log file at path, from oldest to newest
+func rotatedFiles(t *testing.T, path string) []string {
+	files, err := filepath.Glob(strings.TrimSuffix(path, ".log") + "-*.log*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	var r io.Reader
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// writeLines write each line in its own write, rotations must be made at different times to have different names
+func writeLines(f *rotatingFile, lines ...string) {
+	for _, line := range lines {
+		_, _ = f.Write([]byte(line + "\n"))
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func TestRotatingFileRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.log")
+	f := newRotatingFile(path, config.SidecarLog{MaxSize: "10B"})
+	writeLines(f, "line 1", "line 2", "line 3")
+	f.Close()
+
+	if got := readFile(t, path); got != "line 3\n" {
+		t.Errorf("expected current file to only contain last line, got %q", got)
+	}
+	rotated := rotatedFiles(t, path)
+	if len(rotated) != 2 {
+		t.Fatalf("expected 2 rotated files, got %v", rotated)
+	}
+	for i, expected := range []string{"line 1\n", "line 2\n"} {
+		if got := readFile(t, rotated[i]); got != expected {
+			t.Errorf("rotated file %d: expected %q, got %q", i, expected, got)
+		}
+	}
+}
+
+func TestRotatingFileRotateByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.log")
+	f := newRotatingFile(path, config.SidecarLog{MaxAge: "100ms"})
+	writeLines(f, "old 1", "old 2")
+	time.Sleep(150 * time.Millisecond)
+	writeLines(f, "new")
+	f.Close()
+
+	if got := readFile(t, path); got != "new\n" {
+		t.Errorf("expected current file to only contain new line, got %q", got)
+	}
+	rotated := rotatedFiles(t, path)
+	if len(rotated) != 1 || readFile(t, rotated[0]) != "old 1\nold 2\n" {
+		t.Errorf("expected one rotated file with old lines, got %v", rotated)
+	}
+}
+
+func TestRotatingFileKeepMaxFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.log")
+	f := newRotatingFile(path, config.SidecarLog{MaxSize: "5B", MaxFiles: 2, Compress: true})
+	writeLines(f, "l1", "l2", "l3", "l4", "l5")
+	f.Close()
+
+	rotated := rotatedFiles(t, path)
+	if len(rotated) != 2 {
+		t.Fatalf("expected 2 rotated files kept, got %v", rotated)
+	}
+	for i, expected := range []string{"l3\n", "l4\n"} {
+		if !strings.HasSuffix(rotated[i], ".gz") {
+			t.Errorf("expected rotated file %s to be compressed", rotated[i])
+			continue
+		}
+		if got := readFile(t, rotated[i]); got != expected {
+			t.Errorf("rotated file %d: expected %q, got %q", i, expected, got)
+		}
+	}
+}
+
+func TestRotatingFileReopenWhenMoved(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s.log")
+	f := newRotatingFile(path, config.SidecarLog{})
+	defer f.Close()
+	writeLines(f, "before move")
+	moved := filepath.Join(dir, "moved.log")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	// file is checked at most once per check interval
+	time.Sleep(logCheckInterval + 100*time.Millisecond)
+	writeLines(f, "after move")
+
+	if got := readFile(t, moved); got != "before move\n" {
+		t.Errorf("expected moved file to be left untouched, got %q", got)
+	}
+	if got := readFile(t, path); got != "after move\n" {
+		t.Errorf("expected file to be reopened at its path, got %q", got)
+	}
+}
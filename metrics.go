@@ -0,0 +1,254 @@
+package sidecars
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMetricsListen only listen on localhost, metrics are exposed to other hosts only when configured
+const DefaultMetricsListen = "127.0.0.1:9191"
+
+func SetupMetricsFilePath(baseDir string) string {
+	return filepath.Join(baseDir, PathSidecarsWd, "setup-metrics.json")
+}
+
+// SetupMetrics are metrics of setup and download of artifacts,
+// they are stored in a file as setup and launch are not run by same process
+type SetupMetrics struct {
+	SetupDuration             float64                    `json:"setup_duration_seconds"`
+	DownloadArtifactsDuration float64                    `json:"download_artifacts_duration_seconds"`
+	Artifacts                 map[string]ArtifactMetrics `json:"artifacts"`
+}
+
+type ArtifactMetrics struct {
+	DownloadDuration float64 `json:"download_duration_seconds"`
+	DownloadedBytes  int64   `json:"downloaded_bytes"`
+}
+
+// LoadSetupMetrics load setup metrics from file, empty metrics are given when file doesn't exist
+func LoadSetupMetrics(path string) (*SetupMetrics, error) {
+	m := &SetupMetrics{Artifacts: make(map[string]ArtifactMetrics)}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return m, err
+	}
+	err = json.Unmarshal(b, m)
+	if err != nil {
+		return m, err
+	}
+	if m.Artifacts == nil {
+		m.Artifacts = make(map[string]ArtifactMetrics)
+	}
+	return m, nil
+}
+
+func (m SetupMetrics) Store(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, b, 0644)
+}
+
+// storeSetupMetrics update stored setup metrics, artifacts of sidecars which are not in configuration anymore are removed.
+// Failing to store metrics is only logged to never fail setup.
+func (l Launcher) storeSetupMetrics(update func(m *SetupMetrics)) {
+	path := SetupMetricsFilePath(l.sConfig.Dir)
+	m, err := LoadSetupMetrics(path)
+	if err != nil {
+		log.Warnf("Invalid setup metrics file '%s', it will be overwritten: %v", path, err)
+	}
+	update(m)
+	for name := range m.Artifacts {
+		found := false
+		for _, sidecar := range l.sConfig.Sidecars {
+			if sidecar.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(m.Artifacts, name)
+		}
+	}
+	if err := m.Store(path); err != nil {
+		log.Errorf("unable to store setup metrics in '%s': %v", path, err)
+	}
+}
+
+type metricsServer struct {
+	table            *processTable
+	starterName      string
+	setupMetricsPath string
+	listen           string
+	server           *http.Server
+}
+
+func newMetricsServer(table *processTable, starterName, setupMetricsPath, listen string) *metricsServer {
+	s := &metricsServer{
+		table:            table,
+		starterName:      starterName,
+		setupMetricsPath: setupMetricsPath,
+		listen:           listen,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", s.serveMetrics)
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+func (s *metricsServer) Start() error {
+	listener, err := net.Listen("tcp", s.listen)
+	if err != nil {
+		return fmt.Errorf("metrics listen: %s", err.Error())
+	}
+	log.WithField("component", "Metrics").Infof("Metrics available on http://%s/metrics", listener.Addr())
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithField("component", "Metrics").Errorf("metrics server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *metricsServer) Stop() {
+	if err := s.server.Close(); err != nil {
+		log.WithField("component", "Metrics").Errorf("failed to stop metrics server: %v", err)
+	}
+}
+
+func (s *metricsServer) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.writeProcessMetrics(w)
+	s.writeSetupMetrics(w)
+}
+
+func (s *metricsServer) processLabels(status processStatus) map[string]string {
+	labels := map[string]string{
+		"type":    status.Type,
+		"starter": s.starterName,
+	}
+	if status.Type == "sidecar" {
+		labels["sidecar"] = status.Name
+	}
+	return labels
+}
+
+func (s *metricsServer) writeProcessMetrics(w io.Writer) {
+	statuses := make([]processStatus, 0)
+	for _, p := range s.table.all() {
+		statuses = append(statuses, p.status())
+	}
+	writeMetricHeader(w, "cloud_sidecars_process_up", "gauge", "Whether process is running (1) or not (0).")
+	for _, status := range statuses {
+		up := 0.0
+		if status.Running {
+			up = 1
+		}
+		writeMetric(w, "cloud_sidecars_process_up", s.processLabels(status), up)
+	}
+	writeMetricHeader(w, "cloud_sidecars_process_start_time_seconds", "gauge", "Start time of last run of process since unix epoch in seconds.")
+	for _, status := range statuses {
+		if !status.HasRun() {
+			continue
+		}
+		writeMetric(w, "cloud_sidecars_process_start_time_seconds", s.processLabels(status),
+			float64(status.StartedAt.UnixNano())/float64(time.Second))
+	}
+	writeMetricHeader(w, "cloud_sidecars_process_last_exit_code", "gauge", "Exit code of last run of process.")
+	for _, status := range statuses {
+		if status.ExitCode < 0 {
+			continue
+		}
+		writeMetric(w, "cloud_sidecars_process_last_exit_code", s.processLabels(status), float64(status.ExitCode))
+	}
+	writeMetricHeader(w, "cloud_sidecars_process_restarts_total", "counter", "Number of restarts of process.")
+	for _, status := range statuses {
+		writeMetric(w, "cloud_sidecars_process_restarts_total", s.processLabels(status), float64(status.Restarts))
+	}
+}
+
+func (s *metricsServer) writeSetupMetrics(w io.Writer) {
+	m, err := LoadSetupMetrics(s.setupMetricsPath)
+	if err != nil {
+		log.WithField("component", "Metrics").Errorf("unable to load setup metrics: %v", err)
+		return
+	}
+	labels := map[string]string{"starter": s.starterName}
+	writeMetricHeader(w, "cloud_sidecars_setup_duration_seconds", "gauge", "Duration of last setup in seconds.")
+	writeMetric(w, "cloud_sidecars_setup_duration_seconds", labels, m.SetupDuration)
+	writeMetricHeader(w, "cloud_sidecars_download_artifacts_duration_seconds", "gauge", "Duration of last download of artifacts in seconds.")
+	writeMetric(w, "cloud_sidecars_download_artifacts_duration_seconds", labels, m.DownloadArtifactsDuration)
+
+	names := make([]string, 0, len(m.Artifacts))
+	for name := range m.Artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	writeMetricHeader(w, "cloud_sidecars_artifact_download_duration_seconds", "gauge", "Duration of last download of sidecar artifact in seconds.")
+	for _, name := range names {
+		writeMetric(w, "cloud_sidecars_artifact_download_duration_seconds",
+			map[string]string{"sidecar": name, "starter": s.starterName}, m.Artifacts[name].DownloadDuration)
+	}
+	writeMetricHeader(w, "cloud_sidecars_artifact_downloaded_bytes", "gauge", "Size in bytes of last downloaded sidecar artifact.")
+	for _, name := range names {
+		writeMetric(w, "cloud_sidecars_artifact_downloaded_bytes",
+			map[string]string{"sidecar": name, "starter": s.starterName}, float64(m.Artifacts[name].DownloadedBytes))
+	}
+}
+
+func writeMetricHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// writeMetric write a sample in prometheus text format, labels with empty value are omitted
+func writeMetric(w io.Writer, name string, labels map[string]string, value float64) {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, k, escapeLabelValue(labels[k]))
+	}
+	if len(pairs) == 0 {
+		fmt.Fprintf(w, "%s %s\n", name, formatValue(value))
+		return
+	}
+	fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatValue(value))
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
@@ -0,0 +1,87 @@
+package sidecars
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetricEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	writeMetric(&buf, "cloud_sidecars_process_up", map[string]string{
+		"sidecar": "my\"side\\car\nname",
+		"type":    "sidecar",
+		"starter": "",
+	}, 1)
+	expected := `cloud_sidecars_process_up{sidecar="my\"side\\car\nname",type="sidecar"} 1` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	buf.Reset()
+	writeMetric(&buf, "cloud_sidecars_setup_duration_seconds", map[string]string{"starter": ""}, 1.5)
+	if buf.String() != "cloud_sidecars_setup_duration_seconds 1.5\n" {
+		t.Errorf("expected sample without labels, got %q", buf.String())
+	}
+}
+
+func TestMetricsExposition(t *testing.T) {
+	f := newTestFactory(t)
+	p := startTestSidecar(t, f, "db")
+	waitFor(t, 5*time.Second, "sidecar to run", func() bool { return p.status().Running })
+	table := newProcessTable()
+	table.set([]*process{p})
+
+	setupPath := filepath.Join(t.TempDir(), "setup-metrics.json")
+	err := SetupMetrics{
+		SetupDuration: 2.5,
+		Artifacts:     map[string]ArtifactMetrics{"db": {DownloadDuration: 1.25, DownloadedBytes: 1024}},
+	}.Store(setupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newMetricsServer(table, "test", setupPath, "")
+	server := httptest.NewServer(s.server.Handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", resp.Header.Get("Content-Type"))
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(b)
+	for _, expected := range []string{
+		"# HELP cloud_sidecars_process_up Whether process is running (1) or not (0).\n# TYPE cloud_sidecars_process_up gauge\n",
+		`cloud_sidecars_process_up{sidecar="db",starter="test",type="sidecar"} 1` + "\n",
+		`cloud_sidecars_process_restarts_total{sidecar="db",starter="test",type="sidecar"} 0` + "\n",
+		"# TYPE cloud_sidecars_process_restarts_total counter\n",
+		`cloud_sidecars_process_start_time_seconds{sidecar="db",starter="test",type="sidecar"} `,
+		`cloud_sidecars_setup_duration_seconds{starter="test"} 2.5` + "\n",
+		`cloud_sidecars_artifact_download_duration_seconds{sidecar="db",starter="test"} 1.25` + "\n",
+		`cloud_sidecars_artifact_downloaded_bytes{sidecar="db",starter="test"} 1024` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", expected, body)
+		}
+	}
+	// exit code is only known when process has exited
+	if strings.Contains(body, "cloud_sidecars_process_last_exit_code{") {
+		t.Errorf("expected no exit code for running process, got:\n%s", body)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if !strings.HasPrefix(line, "# ") && len(strings.Fields(line)) != 2 {
+			t.Errorf("invalid sample line %q", line)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package sidecars
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxPortPicks is number of free ports picked by system which are tried before giving up
+const maxPortPicks = 100
+
+func PortsFilePath(baseDir string) string {
+	return filepath.Join(baseDir, PathSidecarsWd, "ports.json")
+}
+
+// PortEnvKey give env var name of a declared port of a sidecar, e.g.: SIDECAR_MY_PROXY_PORT_ADMIN
+func PortEnvKey(sidecarName, portName string) string {
+	return fmt.Sprintf("SIDECAR_%s_PORT_%s", envKeyPart(sidecarName), envKeyPart(portName))
+}
+
+func envKeyPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(s))
+}
+
+// PortsEnv give env vars of all declared ports of sidecars
+func PortsEnv(ports map[string]map[string]int) map[string]string {
+	env := make(map[string]string)
+	for sidecarName, sidecarPorts := range ports {
+		for portName, port := range sidecarPorts {
+			env[PortEnvKey(sidecarName, portName)] = strconv.Itoa(port)
+		}
+	}
+	return env
+}
+
+// portAllocator give a port to each port declared by sidecars, to each port of reverse proxies chain and to
+// health server, allocation is stored in a file to give same ports to setup, which write app profile, and to launch
+type portAllocator struct {
+	mu     sync.Mutex
+	path   string
+	ports  map[string]map[string]int
+	chain  []int
+	health int
+}
+
+// storedPorts is content of ports file
+type storedPorts struct {
+	Sidecars map[string]map[string]int `json:"sidecars"`
+	// Chain give port of process behind each reverse proxy, in order of reverse proxies
+	Chain []int `json:"chain,omitempty"`
+	// Health give port of health server when it is not configured
+	Health int `json:"health,omitempty"`
+}
+
+func newPortAllocator(path string) *portAllocator {
+	return &portAllocator{path: path}
+}
+
+// load read stored allocation once, a missing or invalid file give an empty allocation
+func (a *portAllocator) load() {
+	if a.ports != nil {
+		return
+	}
+	a.ports = make(map[string]map[string]int)
+	b, err := os.ReadFile(a.path)
+	if err != nil {
+		return
+	}
+	var stored storedPorts
+	if err := json.Unmarshal(b, &stored); err != nil || stored.Sidecars == nil {
+		return
+	}
+	a.ports = stored.Sidecars
+	a.chain = stored.Chain
+	a.health = stored.Health
+}
+
+func (a *portAllocator) store(ports map[string]map[string]int, chain []int, health int) error {
+	if reflect.DeepEqual(ports, a.ports) && reflect.DeepEqual(chain, a.chain) && health == a.health {
+		return nil
+	}
+	b, err := json.MarshalIndent(storedPorts{Sidecars: ports, Chain: chain, Health: health}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(a.path, b, 0644); err != nil {
+		return fmt.Errorf("unable to store ports allocation in '%s': %s", a.path, err.Error())
+	}
+	a.ports = ports
+	a.chain = chain
+	a.health = health
+	return nil
+}
+
+// fixedPorts add fixed ports declared by sidecars to used ports
+func fixedPorts(sidecars []*config.Sidecar, used map[int]string) error {
+	for _, sidecar := range sidecars {
+		for _, decl := range sidecar.Ports {
+			name, port, err := config.ParsePort(decl)
+			if err != nil {
+				return NewSidecarError(sidecar, err)
+			}
+			if port != 0 {
+				used[port] = fmt.Sprintf("port %s of sidecar %s", name, sidecar.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// allocateChain give port of process behind each reverse proxy, app listen on last one and first reverse proxy
+// listen on first port. A port keeps its previous allocation or is next port after previous process port,
+// a free port is picked when this port is used by a fixed port of a sidecar or, when checkInUse is set,
+// when something else than current processes listen on it.
+func (a *portAllocator) allocateChain(
+	sidecars []*config.Sidecar,
+	firstPort int,
+	current map[int]bool,
+	checkInUse bool,
+) ([]int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.load()
+	used := map[int]string{firstPort: "app or first reverse proxy"}
+	if err := fixedPorts(sidecars, used); err != nil {
+		return nil, err
+	}
+	var chain []int
+	port := firstPort
+	for _, sidecar := range sidecars {
+		if !sidecar.IsRproxy {
+			continue
+		}
+		i := len(chain)
+		port++
+		if i < len(a.chain) {
+			port = a.chain[i]
+		}
+		_, isUsed := used[port]
+		if isUsed || (checkInUse && !current[port] && checkPortFree(port) != nil) {
+			var err error
+			port, err = pickFreePort(used)
+			if err != nil {
+				return nil, NewSidecarError(sidecar, fmt.Errorf("port behind reverse proxy: %s", err.Error()))
+			}
+		}
+		used[port] = fmt.Sprintf("reverse proxy %s", sidecar.Name)
+		chain = append(chain, port)
+	}
+	if err := a.store(a.ports, chain, a.health); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// allocateHealth give port of health server. A configured port is used as is. Otherwise port keeps its previous
+// allocation or is next port after lastPort, port of app behind reverse proxies; a free port is picked when this
+// port is reserved, used by a port of a sidecar or, when checkInUse is set, when something else than health server
+// listen on it. Current port, on which health server already listen, is kept.
+func (a *portAllocator) allocateHealth(
+	sidecars []*config.Sidecar,
+	lastPort int,
+	configured int,
+	current int,
+	reserved map[int]bool,
+	checkInUse bool,
+) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.load()
+	used := make(map[int]string)
+	for port := range reserved {
+		used[port] = "app or reverse proxies"
+	}
+	// ports previously allocated to sidecars are kept for them
+	for sidecarName, sidecarPorts := range a.ports {
+		for name, port := range sidecarPorts {
+			used[port] = fmt.Sprintf("port %s of sidecar %s", name, sidecarName)
+		}
+	}
+	if err := fixedPorts(sidecars, used); err != nil {
+		return 0, err
+	}
+	port := configured
+	if current != 0 {
+		port = current
+	}
+	if port != 0 {
+		if owner, ok := used[port]; ok {
+			return 0, fmt.Errorf("health port %d is already used by %s", port, owner)
+		}
+		if checkInUse && port != current {
+			if err := checkPortFree(port); err != nil {
+				return 0, fmt.Errorf("health port: %s", err.Error())
+			}
+		}
+		return port, nil
+	}
+	port = lastPort + 1
+	if a.health != 0 {
+		port = a.health
+	}
+	_, isUsed := used[port]
+	if isUsed || (checkInUse && checkPortFree(port) != nil) {
+		var err error
+		port, err = pickFreePort(used)
+		if err != nil {
+			return 0, fmt.Errorf("health port: %s", err.Error())
+		}
+	}
+	if err := a.store(a.ports, a.chain, port); err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// allocate give ports of sidecars, fixed ports are used as is and others are picked among free ports.
+// A port keeps its previous allocation (from current processes or from stored allocation) when possible.
+// Ports are checked to not be in use when checkInUse is set, except ports of current sidecars processes.
+func (a *portAllocator) allocate(
+	sidecars []*config.Sidecar,
+	reserved map[int]bool,
+	current map[string]map[string]int,
+	checkInUse bool,
+) (map[string]map[string]int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.load()
+	used := make(map[int]string)
+	for port := range reserved {
+		used[port] = "app or reverse proxies"
+	}
+	ports := make(map[string]map[string]int)
+	toPick := make(map[*config.Sidecar][]string)
+	// fixed ports and previous allocations are taken first to not be picked for another port
+	for _, sidecar := range sidecars {
+		for _, decl := range sidecar.Ports {
+			name, port, err := config.ParsePort(decl)
+			if err != nil {
+				return nil, NewSidecarError(sidecar, err)
+			}
+			isCurrent := current[sidecar.Name][name] != 0 && current[sidecar.Name][name] == port
+			if port == 0 {
+				port = current[sidecar.Name][name]
+				isCurrent = port != 0
+			}
+			if port == 0 {
+				port = a.ports[sidecar.Name][name]
+			}
+			if port == 0 {
+				toPick[sidecar] = append(toPick[sidecar], name)
+				continue
+			}
+			if owner, ok := used[port]; ok {
+				return nil, NewSidecarError(sidecar, fmt.Errorf("port %d of %s is already used by %s", port, name, owner))
+			}
+			if checkInUse && !isCurrent {
+				if err := checkPortFree(port); err != nil {
+					return nil, NewSidecarError(sidecar, fmt.Errorf("port %s: %s", name, err.Error()))
+				}
+			}
+			used[port] = fmt.Sprintf("port %s of sidecar %s", name, sidecar.Name)
+			setPort(ports, sidecar.Name, name, port)
+		}
+	}
+	for _, sidecar := range sidecars {
+		for _, name := range toPick[sidecar] {
+			port, err := pickFreePort(used)
+			if err != nil {
+				return nil, NewSidecarError(sidecar, fmt.Errorf("port %s: %s", name, err.Error()))
+			}
+			used[port] = fmt.Sprintf("port %s of sidecar %s", name, sidecar.Name)
+			setPort(ports, sidecar.Name, name, port)
+		}
+	}
+	if err := a.store(ports, a.chain, a.health); err != nil {
+		return nil, err
+	}
+	return ports, nil
+}
+
+func setPort(ports map[string]map[string]int, sidecarName, portName string, port int) {
+	if _, ok := ports[sidecarName]; !ok {
+		ports[sidecarName] = make(map[string]int)
+	}
+	ports[sidecarName][portName] = port
+}
+
+// checkPortFree verify that nothing listen on port
+func checkPortFree(port int) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("port %d is already in use", port)
+	}
+	return listener.Close()
+}
+
+// pickFreePort let system give a free port which is not already used.
+// Port is free when it is picked but it is released to be given to a process, another program may bind it
+// in between: process then fails to listen and is restarted by its restart policy. A port kept from a previous
+// allocation is not affected once its process listen on it.
+func pickFreePort(used map[int]string) (int, error) {
+	for i := 0; i < maxPortPicks; i++ {
+		listener, err := net.Listen("tcp", ":0")
+		if err != nil {
+			return 0, fmt.Errorf("unable to find a free port: %s", err.Error())
+		}
+		port := listener.Addr().(*net.TCPAddr).Port
+		if err := listener.Close(); err != nil {
+			return 0, err
+		}
+		if _, ok := used[port]; !ok {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("unable to find a free port after %d tries", maxPortPicks)
+}
+
+// chainPorts give ports used by app and reverse proxies chain starting at firstPort
+func chainPorts(firstPort int, chain []int) map[int]bool {
+	reserved := map[int]bool{firstPort: true}
+	for _, port := range chain {
+		reserved[port] = true
+	}
+	return reserved
+}
+
+// currentPorts give ports of current sidecars processes
+func (l Launcher) currentPorts() map[string]map[string]int {
+	current := make(map[string]map[string]int)
+	for _, p := range l.table.all() {
+		if p.sidecar != nil && p.ports != nil {
+			current[p.name] = p.ports
+		}
+	}
+	return current
+}
+
+// currentListenPorts give all ports on which current processes listen
+func (l Launcher) currentListenPorts() map[int]bool {
+	ports := make(map[int]bool)
+	for _, p := range l.table.all() {
+		for _, port := range p.ports {
+			ports[port] = true
+		}
+	}
+	return ports
+}
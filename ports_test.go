@@ -0,0 +1,195 @@
+package sidecars
+
+import (
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// freePortRange give a port which is free with its next n ports
+func freePortRange(t *testing.T, n int) int {
+	for i := 0; i < 100; i++ {
+		port, err := pickFreePort(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		free := true
+		for next := port + 1; next <= port+n; next++ {
+			if checkPortFree(next) != nil {
+				free = false
+				break
+			}
+		}
+		if free {
+			return port
+		}
+	}
+	t.Fatalf("unable to find %d free consecutive ports", n+1)
+	return 0
+}
+
+func rproxies(names ...string) []*config.Sidecar {
+	sidecars := make([]*config.Sidecar, 0, len(names))
+	for _, name := range names {
+		sidecars = append(sidecars, &config.Sidecar{Name: name, IsRproxy: true})
+	}
+	return sidecars
+}
+
+func TestAllocateChainNextPorts(t *testing.T) {
+	first := freePortRange(t, 2)
+	a := newPortAllocator(filepath.Join(t.TempDir(), "ports.json"))
+	chain, err := a.allocateChain(rproxies("p1", "p2"), first, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || chain[0] != first+1 || chain[1] != first+2 {
+		t.Errorf("expected chain [%d %d], got %v", first+1, first+2, chain)
+	}
+}
+
+func TestAllocateChainSkipsUsedPorts(t *testing.T) {
+	first := freePortRange(t, 2)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", first+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	sidecars := rproxies("p1", "p2")
+	// fixed port of a sidecar is never given to chain
+	sidecars = append(sidecars, &config.Sidecar{Name: "admin", Ports: []string{fmt.Sprintf("admin:%d", first+2)}})
+	a := newPortAllocator(filepath.Join(t.TempDir(), "ports.json"))
+	chain, err := a.allocateChain(sidecars, first, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, port := range chain {
+		if port == first || port == first+1 || port == first+2 {
+			t.Errorf("expected chain to avoid used ports %d to %d, got %v", first, first+2, chain)
+		}
+	}
+	if len(chain) != 2 || chain[0] == chain[1] {
+		t.Errorf("expected 2 distinct ports, got %v", chain)
+	}
+
+	// ports of current processes are in use by them and are kept
+	chain, err = newPortAllocator(filepath.Join(t.TempDir(), "ports.json")).
+		allocateChain(rproxies("p1"), first, map[int]bool{first + 1: true}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 1 || chain[0] != first+1 {
+		t.Errorf("expected port of current process %d to be kept, got %v", first+1, chain)
+	}
+}
+
+func TestAllocateChainStored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	first := freePortRange(t, 1)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", first+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := newPortAllocator(path).allocateChain(rproxies("p1"), first, nil, true)
+	listener.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// setup and launch use different allocators, launch must use chain allocated by setup
+	again, err := newPortAllocator(path).allocateChain(rproxies("p1"), first, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 1 || again[0] != chain[0] {
+		t.Errorf("expected stored chain %v, got %v", chain, again)
+	}
+}
+
+func TestHealthAllocatePortAfterChain(t *testing.T) {
+	first := freePortRange(t, 3)
+	reserved := map[int]bool{first: true, first + 1: true}
+	a := newPortAllocator(filepath.Join(t.TempDir(), "ports.json"))
+	// app listen on last port of chain
+	port, err := newHealthServer(newProcessTable(), "", 0, "").allocatePort(a, nil, first+1, reserved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != first+2 {
+		t.Errorf("expected health port %d, got %d", first+2, port)
+	}
+	s := newHealthServer(newProcessTable(), "", first+1, "")
+	if _, err := s.allocatePort(a, nil, first+1, reserved); err == nil {
+		t.Error("expected error for health port used by chain")
+	}
+}
+
+func TestHealthAllocatePortSkipsUsedPorts(t *testing.T) {
+	first := freePortRange(t, 2)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", first+2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// fixed port of a sidecar is never given to health server
+	sidecars := []*config.Sidecar{{Name: "admin", Ports: []string{fmt.Sprintf("admin:%d", first+1)}}}
+	a := newPortAllocator(filepath.Join(t.TempDir(), "ports.json"))
+	s := newHealthServer(newProcessTable(), "", 0, "")
+	port, err := s.allocatePort(a, sidecars, first, map[int]bool{first: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port == first || port == first+1 || port == first+2 {
+		t.Errorf("expected health port to avoid used ports %d to %d, got %d", first, first+2, port)
+	}
+
+	// configured port is checked like allocated ones
+	s = newHealthServer(newProcessTable(), "", first+1, "")
+	if _, err := s.allocatePort(a, sidecars, first, map[int]bool{first: true}); err == nil {
+		t.Error("expected error for health port used by a sidecar")
+	}
+	s = newHealthServer(newProcessTable(), "", first+2, "")
+	if _, err := s.allocatePort(a, nil, first, map[int]bool{first: true}); err == nil {
+		t.Error("expected error for health port in use")
+	}
+}
+
+func TestHealthAllocatePortStored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	first := freePortRange(t, 1)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", first+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := newHealthServer(newProcessTable(), "", 0, "").
+		allocatePort(newPortAllocator(path), nil, first, map[int]bool{first: true})
+	listener.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port == first+1 {
+		t.Fatalf("expected health port to avoid port in use %d", first+1)
+	}
+	// health server keeps its port across launches even when next port after app is free again
+	s := newHealthServer(newProcessTable(), "", 0, "")
+	again, err := s.allocatePort(newPortAllocator(path), nil, first, map[int]bool{first: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != port {
+		t.Errorf("expected stored health port %d, got %d", port, again)
+	}
+	// port is kept on reload once health server listen on it
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	again, err = s.allocatePort(newPortAllocator(path), nil, first, map[int]bool{first: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != port {
+		t.Errorf("expected current health port %d, got %d", port, again)
+	}
+}
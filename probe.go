@@ -0,0 +1,203 @@
+package sidecars
+
+import (
+	"context"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	DefaultProbeTimeout      = 1 * time.Minute
+	DefaultProbeInterval     = 1 * time.Second
+	DefaultProbeCheckTimeout = 1 * time.Second
+	DefaultFailureThreshold  = 3
+)
+
+type prober struct {
+	tcp              string
+	http             string
+	httpStatus       int
+	exec             []string
+	file             string
+	env              []string
+	wd               string
+	timeout          time.Duration
+	interval         time.Duration
+	checkTimeout     time.Duration
+	initialDelay     time.Duration
+	failureThreshold int
+}
+
+// newProber create a prober from a probe config, tcp address, http url, file path and exec command
+// are templated with env given, this let user set probe on a port given by env var (e.g.: ${PROXY_APP_PORT})
+func newProber(probe *config.Probe, env map[string]string, wd string) (*prober, error) {
+	tcp, err := TemplatingFromEnv(env, probe.TCP)
+	if err != nil {
+		return nil, err
+	}
+	httpUrl, err := TemplatingFromEnv(env, probe.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	file, err := TemplatingFromEnv(env, probe.File)
+	if err != nil {
+		return nil, err
+	}
+	execArgs, err := TemplatingArgs(env, append([]string{}, probe.Exec...)...)
+	if err != nil {
+		return nil, err
+	}
+	failureThreshold := probe.FailureThreshold
+	if failureThreshold == 0 {
+		failureThreshold = DefaultFailureThreshold
+	}
+	// durations are validated with configuration, defaults protect probes built without check
+	return &prober{
+		tcp:              tcp,
+		http:             httpUrl,
+		httpStatus:       probe.HTTPStatus,
+		exec:             execArgs,
+		file:             file,
+		env:              utils.EnvMapToOsEnv(env),
+		wd:               wd,
+		timeout:          positiveDuration(probe.Timeout, DefaultProbeTimeout),
+		interval:         positiveDuration(probe.Interval, DefaultProbeInterval),
+		checkTimeout:     positiveDuration(probe.CheckTimeout, DefaultProbeCheckTimeout),
+		initialDelay:     probe.InitialDelay.Value(0),
+		failureThreshold: failureThreshold,
+	}, nil
+}
+
+// positiveDuration give duration or def if duration is not set, invalid or not positive
+func positiveDuration(d config.Duration, def time.Duration) time.Duration {
+	v := d.Value(def)
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// check run probe one time
+func (p prober) check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.checkTimeout)
+	defer cancel()
+	switch {
+	case p.tcp != "":
+		return p.checkTCP(ctx)
+	case p.http != "":
+		return p.checkHTTP(ctx)
+	case len(p.exec) > 0:
+		return p.checkExec(ctx)
+	case p.file != "":
+		return p.checkFile()
+	}
+	return fmt.Errorf("no probe defined")
+}
+
+func (p prober) checkTCP(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.tcp)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (p prober) checkHTTP(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.http, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if p.httpStatus != 0 && resp.StatusCode != p.httpStatus {
+		return fmt.Errorf("http probe on '%s' expected status %d but got %d", p.http, p.httpStatus, resp.StatusCode)
+	}
+	if p.httpStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode >= 400) {
+		return fmt.Errorf("http probe on '%s' got status %d", p.http, resp.StatusCode)
+	}
+	return nil
+}
+
+func (p prober) checkExec(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, p.exec[0], p.exec[1:]...)
+	cmd.Env = p.env
+	cmd.Dir = p.wd
+	return runManaged(cmd)
+}
+
+func (p prober) checkFile() error {
+	_, err := os.Stat(p.file)
+	return err
+}
+
+// waitReady run probe until it succeed, it fails if probe doesn't succeed before timeout,
+// if stop channel is closed or if process is done
+func (p prober) waitReady(stopChan, doneChan chan struct{}) error {
+	timeout := time.NewTimer(p.timeout)
+	defer timeout.Stop()
+	var err error
+	for {
+		err = p.check()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-stopChan:
+			return fmt.Errorf("stopped before being ready")
+		case <-doneChan:
+			return fmt.Errorf("exited before being ready")
+		case <-timeout.C:
+			return fmt.Errorf("not ready after %s: %s", p.timeout, err.Error())
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+// watchLiveness run probe periodically until done channel is closed,
+// onFailure is called when probe failed failure threshold times consecutively
+func (p prober) watchLiveness(entry *log.Entry, done chan struct{}, onFailure func(err error)) {
+	select {
+	case <-done:
+		return
+	case <-time.After(p.initialDelay):
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		err := p.check()
+		if err == nil {
+			if failures > 0 {
+				entry.Info("Liveness probe succeeded.")
+			} else {
+				entry.Debug("Liveness probe succeeded.")
+			}
+			failures = 0
+			continue
+		}
+		failures++
+		entry.WithField("failures", failures).Warnf("Liveness probe failed: %s", err.Error())
+		if failures >= p.failureThreshold {
+			onFailure(err)
+			return
+		}
+	}
+}
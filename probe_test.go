@@ -0,0 +1,197 @@
+package sidecars
+
+import (
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewProberNonPositiveDurations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ready")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newProber(&config.Probe{
+		File:         file,
+		Timeout:      "0s",
+		Interval:     "0s",
+		CheckTimeout: "-1s",
+	}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.timeout != DefaultProbeTimeout || p.interval != DefaultProbeInterval || p.checkTimeout != DefaultProbeCheckTimeout {
+		t.Errorf("expected default durations, got timeout=%s interval=%s check_timeout=%s", p.timeout, p.interval, p.checkTimeout)
+	}
+	if err := p.check(); err != nil {
+		t.Errorf("check must succeed with default check timeout: %v", err)
+	}
+	// must not panic with a non-positive ticker interval
+	done := make(chan struct{})
+	close(done)
+	p.watchLiveness(testEntry(), done, func(error) {})
+}
+
+func TestProberWaitReadyHTTP(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	p, err := newProber(&config.Probe{HTTP: server.URL, Interval: "10ms", Timeout: "5s"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.waitReady(make(chan struct{}), make(chan struct{})); err != nil {
+		t.Fatalf("expected probe to be ready: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected 3 checks, got %d", n)
+	}
+}
+
+func TestProberWaitReadyFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	// status differs from expected one
+	p, err := newProber(&config.Probe{HTTP: server.URL, HTTPStatus: 204, Interval: "10ms", Timeout: "100ms"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.waitReady(make(chan struct{}), make(chan struct{}))
+	if err == nil || !strings.Contains(err.Error(), "not ready after") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+
+	p, err = newProber(&config.Probe{TCP: freeAddr(t), Interval: "10ms", Timeout: "1m"}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopChan := make(chan struct{})
+	close(stopChan)
+	err = p.waitReady(stopChan, make(chan struct{}))
+	if err == nil || !strings.Contains(err.Error(), "stopped") {
+		t.Errorf("expected a stopped error, got %v", err)
+	}
+	doneChan := make(chan struct{})
+	close(doneChan)
+	err = p.waitReady(make(chan struct{}), doneChan)
+	if err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Errorf("expected an exited error, got %v", err)
+	}
+}
+
+func TestProberLivenessThreshold(t *testing.T) {
+	// failures are consecutive only when no success happen between them
+	statuses := []int{500, 500, 200, 500, 500, 500}
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&calls, 1)) - 1
+		if i >= len(statuses) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(statuses[i])
+	}))
+	defer server.Close()
+	p, err := newProber(&config.Probe{HTTP: server.URL, Interval: "10ms", FailureThreshold: 3}, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	failed := make(chan int32, 1)
+	go p.watchLiveness(testEntry(), done, func(error) {
+		failed <- atomic.LoadInt32(&calls)
+	})
+	select {
+	case n := <-failed:
+		if n != int32(len(statuses)) {
+			t.Errorf("expected failure after %d checks, got %d", len(statuses), n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("liveness failure not detected")
+	}
+	close(done)
+}
+
+func TestReadinessGatesDependents(t *testing.T) {
+	f := newTestFactory(t)
+	l := Launcher{processFactory: f}
+	addr := freeAddr(t)
+	dep, err := f.FromSidecar(&config.Sidecar{
+		Name:       "dep",
+		Executable: "sleep",
+		Args:       []string{"30"},
+		Readiness:  &config.Probe{TCP: addr, Interval: "10ms", Timeout: "10s"},
+	}, testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := f.FromSidecar(&config.Sidecar{
+		Name:       "app",
+		Executable: "sleep",
+		Args:       []string{"30"},
+		DependsOn:  []string{"dep"},
+	}, testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.remove()
+	defer dep.remove()
+	f.hold(2)
+	depErr := make(chan error, 1)
+	go func() { depErr <- l.startProcess(dep, nil) }()
+	go l.startProcess(app, []*process{dep})
+
+	waitFor(t, 5*time.Second, "dep to start", func() bool { return isClosed(dep.startedChan) })
+	time.Sleep(100 * time.Millisecond)
+	if dep.isReady() || isClosed(app.startedChan) {
+		t.Fatal("app must not start before dep is ready")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	select {
+	case err := <-depErr:
+		if err != nil {
+			t.Fatalf("dep not ready: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dep not ready after listener started")
+	}
+	waitFor(t, 5*time.Second, "app to start", func() bool { return isClosed(app.startedChan) })
+}
+
+func TestLivenessFailureRestartsSidecar(t *testing.T) {
+	f := newTestFactory(t)
+	p, err := f.FromSidecar(&config.Sidecar{
+		Name:           "unhealthy",
+		Executable:     "sleep",
+		Args:           []string{"30"},
+		Restart:        config.RestartAlways,
+		RestartBackoff: "100ms",
+		Liveness:       &config.Probe{TCP: freeAddr(t), Interval: "10ms", FailureThreshold: 2},
+	}, testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.hold(1)
+	go p.Start()
+	defer p.remove()
+	waitFor(t, 10*time.Second, "sidecar to be restarted", func() bool { return p.status().Restarts >= 1 })
+}
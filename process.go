@@ -2,37 +2,212 @@ package sidecars
 
 import (
 	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"github.com/orange-cloudfoundry/cloud-sidecars/cron"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"os/exec"
 	"sync"
 	"syscall"
+	"time"
+)
+
+// DefaultKillDelay is time given to a process stopped by launcher outside of shutdown to stop before being killed
+const DefaultKillDelay = 10 * time.Second
+
+const (
+	requestStop    = "stop"
+	requestRestart = "restart"
+)
+
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateWaiting   = "waiting"
+	StateStopped   = "stopped"
+	StateExited    = "exited"
+	StateScheduled = "scheduled"
 )
 
 type process struct {
-	cmd             *exec.Cmd
-	cmdHandler      CmdHandler
-	name            string
-	typeP           string
-	noInterrupt     bool
-	alwaysInterrupt bool
-	errChan         chan error
-	signalChan      chan os.Signal
-	wg              *sync.WaitGroup
+	mu               sync.Mutex
+	cmd              *exec.Cmd
+	cmdHandler       CmdHandler
+	output           *processOutput
+	sidecar          *config.Sidecar
+	env              map[string]string
+	wd               string
+	factory          *ProcessFactory
+	name             string
+	typeP            string
+	noInterrupt      bool
+	alwaysInterrupt  bool
+	restartPolicy    *restartPolicy
+	liveness         *prober
+	schedule         *cron.Schedule
+	unhealthy        error
+	running          bool
+	startedAt        time.Time
+	exitedAt         time.Time
+	exitCode         int
+	exitSignal       string
+	restarts         int
+	ports            map[string]int
+	request          string
+	stoppedOnRequest bool
+	runDone          chan struct{}
+	startChan        chan struct{}
+	errChan          chan error
+	signalChan       chan os.Signal
+	stopChan         chan struct{}
+	quitChan         chan struct{}
+	quitOnce         sync.Once
+	startedChan      chan struct{}
+	startedOnce      sync.Once
+	readyChan        chan struct{}
+	doneChan         chan struct{}
+	// lastDiagnostic is only used by goroutine running process
+	lastDiagnostic time.Time
+	// scheduleTicks replace schedule timer when set, it is used by tests to reach schedule by hand
+	scheduleTicks chan time.Time
 }
 
 func (p *process) Start() {
+	defer p.factory.release()
+	defer p.notifyChange()
+	defer close(p.doneChan)
+	if p.schedule != nil {
+		p.runScheduled()
+		return
+	}
 	entry := log.WithField(p.typeP, p.name)
-	entry.Infof("Starting %s %s ...", p.typeP, p.name)
-	defer p.wg.Done()
-	err := p.cmdHandler.Run()
-	if err != nil {
+	for {
+		startedAt := time.Now()
+		err := p.run()
 		// if this come from a signal, we do not considered this as an error
+		if p.isStopping() {
+			return
+		}
+		switch p.takeRequest() {
+		case requestRestart:
+			entry.Infof("Restarting %s %s on request ...", p.typeP, p.name)
+			if err := p.rebuild(); err != nil {
+				p.exited(entry, err)
+				return
+			}
+			p.restarted("request")
+			continue
+		case requestStop:
+			entry.Infof("%s %s stopped on request.", p.typeP, p.name)
+			select {
+			case <-p.stopChan:
+				return
+			case <-p.quitChan:
+				return
+			case <-p.startChan:
+			}
+			if err := p.rebuild(); err != nil {
+				p.exited(entry, err)
+				return
+			}
+			continue
+		}
+		runtime := time.Since(startedAt)
+		wait, restart := p.restartPolicy.next(err, runtime)
+		if p.unexpectedExit(err, runtime) && (!restart || p.diagnosticDue()) {
+			p.logDiagnostic(err)
+		}
+		if !restart {
+			p.exited(entry, err)
+			return
+		}
+		if err != nil {
+			entry.Errorf("Error occurred on %s %s: %s", p.typeP, p.name, err.Error())
+		}
+		attempts := fmt.Sprintf("%d", p.restartPolicy.attempts)
+		if p.restartPolicy.maxRetries > 0 {
+			attempts += fmt.Sprintf("/%d", p.restartPolicy.maxRetries)
+		}
+		entry.WithField("attempt", p.restartPolicy.attempts).
+			Warnf("Restarting %s %s in %s (attempt %s) ...", p.typeP, p.name, wait, attempts)
 		select {
-		case <-p.signalChan:
+		case <-p.stopChan:
 			return
-		default:
+		case <-p.quitChan:
+			return
+		case <-time.After(wait):
 		}
+		err = p.rebuild()
+		if err != nil {
+			p.exited(entry, err)
+			return
+		}
+		p.restarted("restart_policy")
+	}
+}
+
+// RunOnce run process until it finishes and fail if it exits with an error
+// or if it doesn't finish before its timeout
+func (p *process) RunOnce() error {
+	entry := log.WithField(p.typeP, p.name)
+	defer p.notifyChange()
+	defer close(p.doneChan)
+	err := p.runWithTimeout(p.sidecar.Timeout.Value(0))
+	if p.isStopping() {
+		return nil
+	}
+	if err != nil {
+		p.logDiagnostic(err)
+		return NewSidecarError(p.sidecar, err)
+	}
+	entry.Infof("Finished %s %s.", p.typeP, p.name)
+	close(p.readyChan)
+	p.factory.events.emit(processEvent(EventProcessReady, p))
+	return nil
+}
+
+// runWithTimeout run process and kill it if it doesn't finish before timeout, 0 means no timeout
+func (p *process) runWithTimeout(timeout time.Duration) error {
+	entry := log.WithField(p.typeP, p.name)
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timeoutChan = time.After(timeout)
+	}
+	errRun := make(chan error, 1)
+	go func() {
+		errRun <- p.run()
+	}()
+	select {
+	case err := <-errRun:
+		return err
+	case <-timeoutChan:
+	}
+	entry.Errorf("%s %s did not finish after %s, killing it ...", p.typeP, p.name, timeout)
+	if errKill := p.kill(); errKill != nil {
+		entry.Errorf("failed to kill %s %s: %v", p.typeP, p.name, errKill)
+	}
+	<-errRun
+	return fmt.Errorf("did not finish after %s", timeout)
+}
+
+// cancel mark process as done when it will never be started
+func (p *process) cancel() {
+	p.factory.release()
+	close(p.doneChan)
+	p.notifyChange()
+}
+
+// notifyChange notify that state of process changed, notification is dropped if one is already pending
+func (p *process) notifyChange() {
+	select {
+	case p.factory.changeChan <- struct{}{}:
+	default:
+	}
+}
+
+func (p *process) exited(entry *log.Entry, err error) {
+	if err != nil {
 		errMess := fmt.Sprintf("Error occurred on %s %s: %s", p.typeP, p.name, err.Error())
 		entry.Error(errMess)
 		if !p.noInterrupt {
@@ -45,3 +220,377 @@ func (p *process) Start() {
 		p.signalChan <- syscall.SIGINT
 	}
 }
+
+func (p *process) run() error {
+	p.mu.Lock()
+	cmdHandler := p.cmdHandler
+	cmd := p.cmd
+	p.unhealthy = nil
+	// do not start process if we are already stopping all processes
+	if p.isStopping() {
+		p.mu.Unlock()
+		return nil
+	}
+	log.WithField(p.typeP, p.name).Infof("Starting %s %s ...", p.typeP, p.name)
+	err := managedChildren.start(cmdHandler.Start, cmd)
+	p.running = err == nil
+	p.startedAt = time.Now()
+	p.exitedAt = time.Time{}
+	// done is published with running state as a request on a running process wait for its run to end
+	done := make(chan struct{})
+	if err != nil {
+		p.exitedAt = p.startedAt
+	} else {
+		p.output.setPid(cmd.Process.Pid)
+		p.runDone = done
+	}
+	p.mu.Unlock()
+	p.notifyChange()
+	if err != nil {
+		return err
+	}
+	p.startedOnce.Do(func() {
+		close(p.startedChan)
+	})
+	started := processEvent(EventProcessStarted, p)
+	// cmd has been started under lock, it may be replaced by a rebuild only after this run
+	started.Pid = cmd.Process.Pid
+	p.factory.events.emit(started)
+	if p.liveness != nil {
+		go p.liveness.watchLiveness(log.WithField(p.typeP, p.name), done, func(errProbe error) {
+			p.stopUnhealthy(errProbe, done)
+		})
+	}
+	err = cmdHandler.Wait()
+	managedChildren.release(cmd)
+	close(done)
+	p.mu.Lock()
+	output := p.output
+	p.mu.Unlock()
+	output.flush()
+	defer p.notifyChange()
+	p.mu.Lock()
+	p.running = false
+	p.exitedAt = time.Now()
+	p.exitCode, p.exitSignal = exitStatus(p.cmd)
+	if p.unhealthy != nil {
+		err = fmt.Errorf("liveness probe failed: %s", p.unhealthy.Error())
+	}
+	exited := processEvent(EventProcessExited, p)
+	exited.Signal = p.exitSignal
+	exited.Duration = p.exitedAt.Sub(p.startedAt).Seconds()
+	if p.exitCode >= 0 {
+		exitCode := p.exitCode
+		exited.ExitCode = &exitCode
+	}
+	p.mu.Unlock()
+	if err != nil {
+		exited.Error = err.Error()
+	}
+	p.factory.events.emit(exited)
+	return err
+}
+
+// restarted count a new start of process
+func (p *process) restarted(reason string) {
+	p.mu.Lock()
+	p.restarts++
+	event := processEvent(EventProcessRestarted, p)
+	event.Restarts = p.restarts
+	event.Reason = reason
+	p.mu.Unlock()
+	p.factory.events.emit(event)
+}
+
+// stopUnhealthy stop process and all its sub processes when liveness probe failed,
+// restart policy will then decide if process must be restarted or if it must stop all other processes
+func (p *process) stopUnhealthy(errProbe error, done chan struct{}) {
+	entry := log.WithField(p.typeP, p.name)
+	entry.Errorf("%s %s is not alive, stopping it ...", p.typeP, p.name)
+	p.mu.Lock()
+	p.unhealthy = errProbe
+	p.mu.Unlock()
+	p.terminate(done)
+}
+
+// terminate send stop signal to the current run of process and kill it if it is still running after kill delay,
+// done must be closed when current run finishes
+func (p *process) terminate(done chan struct{}) {
+	entry := log.WithField(p.typeP, p.name)
+	sig := p.stopSignal(syscall.SIGTERM)
+	if err := p.signal(sig); err != nil {
+		entry.Errorf("failing to send signal '%s' to %s %s: %v", sig, p.typeP, p.name, err)
+	}
+	killDelay := p.killDelay()
+	select {
+	case <-done:
+		return
+	case <-time.After(killDelay):
+	}
+	entry.Warnf("%s %s still running after %s, killing it ...", p.typeP, p.name, killDelay)
+	if err := p.signal(os.Kill); err != nil {
+		entry.Errorf("failed to kill %s %s: %v", p.typeP, p.name, err)
+	}
+}
+
+// controllable says if process can be stopped, started or restarted on request,
+// only sidecars run as services can be
+func (p *process) controllable() error {
+	if p.sidecar == nil {
+		return fmt.Errorf("%s %s cannot be controlled, only sidecars can", p.typeP, p.name)
+	}
+	if p.sidecar.IsInit() || p.sidecar.IsScheduled() {
+		return fmt.Errorf("%s %s cannot be controlled, init and scheduled sidecars cannot", p.typeP, p.name)
+	}
+	return nil
+}
+
+// requestStop stop process until a start is requested, process is killed if it doesn't stop before its kill delay.
+// It returns when process is stopped.
+func (p *process) requestStop() error {
+	return p.requestEndOfRun(requestStop)
+}
+
+// requestRestart stop process and start it again right after it stopped. It returns when process is stopped.
+func (p *process) requestRestart() error {
+	return p.requestEndOfRun(requestRestart)
+}
+
+func (p *process) requestEndOfRun(request string) error {
+	if err := p.controllable(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	if !p.running || p.isStopping() {
+		p.mu.Unlock()
+		return fmt.Errorf("%s %s is not running", p.typeP, p.name)
+	}
+	if p.request != "" {
+		p.mu.Unlock()
+		return fmt.Errorf("%s %s is already requested to %s", p.typeP, p.name, p.request)
+	}
+	p.request = request
+	p.stoppedOnRequest = request == requestStop
+	done := p.runDone
+	p.mu.Unlock()
+	p.notifyChange()
+	p.terminate(done)
+	<-done
+	return nil
+}
+
+// requestStart start again a process stopped on request
+func (p *process) requestStart() error {
+	if err := p.controllable(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.stoppedOnRequest {
+		return fmt.Errorf("%s %s has not been stopped on request", p.typeP, p.name)
+	}
+	p.stoppedOnRequest = false
+	p.startChan <- struct{}{}
+	p.notifyChange()
+	return nil
+}
+
+// takeRequest give request made on process since its last start
+func (p *process) takeRequest() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	request := p.request
+	p.request = ""
+	return request
+}
+
+// rebuild create a new command for this process as an exec.Cmd cannot be reused after it has been run
+func (p *process) rebuild() error {
+	if p.sidecar == nil {
+		return fmt.Errorf("%s %s cannot be restarted", p.typeP, p.name)
+	}
+	np, err := p.factory.FromSidecar(p.sidecar, p.env)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.output.close()
+	p.cmd = np.cmd
+	p.cmdHandler = np.cmdHandler
+	p.output = np.output
+	return nil
+}
+
+// isStopping says if all processes are stopping or if this process has been removed
+func (p *process) isStopping() bool {
+	select {
+	case <-p.stopChan:
+		return true
+	case <-p.quitChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// remove stop process for good without considering it as an error and wait for it to be done,
+// process is killed if it doesn't stop before its kill delay
+func (p *process) remove() {
+	p.quitOnce.Do(func() {
+		close(p.quitChan)
+	})
+	p.mu.Lock()
+	running := p.running
+	done := p.runDone
+	p.mu.Unlock()
+	if running {
+		p.terminate(done)
+	}
+	<-p.doneChan
+	p.mu.Lock()
+	output := p.output
+	p.mu.Unlock()
+	output.close()
+}
+
+func (p *process) isReady() bool {
+	select {
+	case <-p.readyChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// signal send signal to process if it is running
+func (p *process) signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.running {
+		return nil // process is not running (which probably create signal)
+	}
+	// if setpgid exist in sysproc, signal is sent to the process group
+	// this will stop all sub process that one of our sidecars or app has started
+	return utils.SignalProcess(p.cmd.Process, p.cmd.SysProcAttr, sig)
+}
+
+// kill process and all its sub processes if it is still running
+func (p *process) kill() error {
+	return p.signal(os.Kill)
+}
+
+// stopSignal give signal to send to stop process, app receive signal received by launcher
+// and sidecars receive their stop signal (SIGTERM by default)
+func (p *process) stopSignal(received os.Signal) os.Signal {
+	if p.sidecar == nil {
+		return received
+	}
+	if p.sidecar.StopSignal == "" {
+		return syscall.SIGTERM
+	}
+	sig, err := utils.ParseSignal(p.sidecar.StopSignal)
+	if err != nil {
+		return syscall.SIGTERM
+	}
+	return sig
+}
+
+// killDelay give time to wait for a process stopped outside of shutdown before killing it
+func (p *process) killDelay() time.Duration {
+	if stopTimeout := p.stopTimeout(); stopTimeout > 0 {
+		return stopTimeout
+	}
+	return DefaultKillDelay
+}
+
+// stopTimeout give time to wait for process to stop before killing it, 0 means no timeout
+func (p *process) stopTimeout() time.Duration {
+	if p.sidecar == nil {
+		return 0
+	}
+	return p.sidecar.StopTimeout.Value(0)
+}
+
+type processStatus struct {
+	Name       string
+	Type       string
+	State      string
+	Pid        int
+	Running    bool
+	StartedAt  time.Time
+	ExitedAt   time.Time
+	ExitCode   int
+	ExitSignal string
+	Restarts   int
+}
+
+// HasRun says if process has been started at least one time
+func (s processStatus) HasRun() bool {
+	return !s.StartedAt.IsZero()
+}
+
+// Runtime give time process has run (or is running) since its last start
+func (s processStatus) Runtime() time.Duration {
+	if !s.HasRun() {
+		return 0
+	}
+	if s.ExitedAt.IsZero() {
+		return time.Since(s.StartedAt)
+	}
+	return s.ExitedAt.Sub(s.StartedAt)
+}
+
+func (p *process) status() processStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// pid of last run is kept when process exited
+	pid := 0
+	if p.cmd.Process != nil {
+		pid = p.cmd.Process.Pid
+	}
+	return processStatus{
+		Name:       p.name,
+		Type:       p.typeP,
+		State:      p.state(),
+		Pid:        pid,
+		Running:    p.running,
+		StartedAt:  p.startedAt,
+		ExitedAt:   p.exitedAt,
+		ExitCode:   p.exitCode,
+		ExitSignal: p.exitSignal,
+		Restarts:   p.restarts,
+	}
+}
+
+// state give current state of process, lock must be held
+func (p *process) state() string {
+	select {
+	case <-p.doneChan:
+		return StateExited
+	default:
+	}
+	switch {
+	case p.running:
+		return StateRunning
+	case p.stoppedOnRequest:
+		return StateStopped
+	case p.schedule != nil:
+		return StateScheduled
+	case !p.startedAt.IsZero():
+		return StateWaiting
+	}
+	return StatePending
+}
+
+// exitStatus give exit code of an exited command and name of signal which stopped it if any,
+// as in shells exit code is 128 + signal number when command has been stopped by a signal
+func exitStatus(cmd *exec.Cmd) (int, string) {
+	if cmd.ProcessState == nil {
+		return -1, ""
+	}
+	if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal()), ws.Signal().String()
+	}
+	return cmd.ProcessState.ExitCode(), ""
+}
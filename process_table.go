@@ -0,0 +1,57 @@
+package sidecars
+
+import "sync"
+
+// processTable hold processes run by launcher, it is shared with components which inspect or act on processes
+type processTable struct {
+	mu        sync.RWMutex
+	processes []*process
+}
+
+func newProcessTable() *processTable {
+	return &processTable{}
+}
+
+func (t *processTable) set(processes []*process) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.processes = processes
+}
+
+// all give processes in launch order, processes which are not created yet are omitted
+func (t *processTable) all() []*process {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	processes := make([]*process, 0, len(t.processes))
+	for _, p := range t.processes {
+		if p != nil {
+			processes = append(processes, p)
+		}
+	}
+	return processes
+}
+
+// get give process by its name, sidecars take precedence over app
+func (t *processTable) get(name string) *process {
+	var found *process
+	for _, p := range t.all() {
+		if p.name != name {
+			continue
+		}
+		if p.sidecar != nil {
+			return p
+		}
+		found = p
+	}
+	return found
+}
+
+// app give app process, nil if there is no app
+func (t *processTable) app() *process {
+	for _, p := range t.all() {
+		if p.sidecar == nil {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package sidecars
+
+import (
+	"os/exec"
+	"sync"
+)
+
+// childRegistry keep pids of processes started by launcher, reaper must never wait for them
+// to not steal their exit status from their exec.Cmd
+type childRegistry struct {
+	mu   sync.Mutex
+	pids map[int]struct{}
+}
+
+var managedChildren = &childRegistry{
+	pids: make(map[int]struct{}),
+}
+
+// start run start function and register pid of started command, lock is held during start
+// to prevent reaper to see a child before it is registered
+func (r *childRegistry) start(start func() error, cmd *exec.Cmd) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := start()
+	if err != nil || cmd.Process == nil {
+		return err
+	}
+	r.pids[cmd.Process.Pid] = struct{}{}
+	return nil
+}
+
+// release forget pid of command, it must be called once command has been waited
+func (r *childRegistry) release(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pids, cmd.Process.Pid)
+}
+
+// isManaged says if pid has been started by launcher, lock must be held
+func (r *childRegistry) isManaged(pid int) bool {
+	_, ok := r.pids[pid]
+	return ok
+}
+
+// runManaged run command as a managed child to let reaper ignore it
+func runManaged(cmd *exec.Cmd) error {
+	if err := managedChildren.start(cmd.Start, cmd); err != nil {
+		return err
+	}
+	defer managedChildren.release(cmd)
+	return cmd.Wait()
+}
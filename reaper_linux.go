@@ -0,0 +1,93 @@
+//go:build linux
+
+package sidecars
+
+import (
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const prSetChildSubreaper = 36
+
+// setSubreaper make launcher adopt orphaned descendants instead of PID 1
+func setSubreaper() error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// runReaper reap orphaned processes adopted by launcher each time a child exits until done is closed
+func runReaper(done chan struct{}) {
+	entry := log.WithField("component", "Reaper")
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGCHLD)
+	defer signal.Stop(sigChan)
+	entry.Debug("Reaping orphaned processes")
+	for {
+		// SIGCHLD can be coalesced, all zombies are looked for on each signal
+		reapOrphans(entry)
+		select {
+		case <-done:
+			return
+		case <-sigChan:
+		}
+	}
+}
+
+// reapOrphans wait for each zombie child of launcher which has not been started by launcher
+func reapOrphans(entry *log.Entry) {
+	managedChildren.mu.Lock()
+	defer managedChildren.mu.Unlock()
+	for _, pid := range zombieChildren(os.Getpid()) {
+		if managedChildren.isManaged(pid) {
+			continue
+		}
+		var ws syscall.WaitStatus
+		wpid, err := syscall.Wait4(pid, &ws, syscall.WNOHANG, nil)
+		if err != nil || wpid != pid {
+			continue
+		}
+		entry.Debugf("Reaped orphaned process %d (exit status %d)", pid, ws.ExitStatus())
+	}
+}
+
+// zombieChildren give pids of zombie processes having ppid as parent
+func zombieChildren(ppid int) []int {
+	pids := make([]int, 0)
+	statFiles, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return pids
+	}
+	for _, statFile := range statFiles {
+		b, err := os.ReadFile(statFile)
+		if err != nil {
+			continue
+		}
+		stat := string(b)
+		// command name is between parenthesis and may contain spaces
+		i := strings.LastIndexByte(stat, ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(stat[i+1:])
+		if len(fields) < 2 || fields[0] != "Z" {
+			continue
+		}
+		if parent, err := strconv.Atoi(fields[1]); err != nil || parent != ppid {
+			continue
+		}
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(statFile)))
+		if err != nil {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
@@ -0,0 +1,48 @@
+//go:build linux
+
+package sidecars
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReaperReapsOrphans(t *testing.T) {
+	if err := setSubreaper(); err != nil {
+		t.Skipf("unable to act as subreaper: %v", err)
+	}
+	defer syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 0, 0)
+
+	// child fork a grandchild and exits, grandchild is then adopted by test process
+	cmd := exec.Command("sh", "-c", "sleep 0.2 & echo $!")
+	cmd.Stdout = &strings.Builder{}
+	if err := runManaged(cmd); err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := strconv.Atoi(strings.TrimSpace(cmd.Stdout.(*strings.Builder).String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	isZombie := func() bool {
+		for _, pid := range zombieChildren(os.Getpid()) {
+			if pid == orphan {
+				return true
+			}
+		}
+		return false
+	}
+	waitFor(t, 5*time.Second, "orphan to become a zombie", isZombie)
+
+	done := make(chan struct{})
+	defer close(done)
+	go runReaper(done)
+	waitFor(t, 5*time.Second, "zombie to be reaped", func() bool {
+		_, err := os.Stat("/proc/" + strconv.Itoa(orphan))
+		return os.IsNotExist(err)
+	})
+}
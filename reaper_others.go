@@ -0,0 +1,14 @@
+//go:build !linux
+
+package sidecars
+
+import "fmt"
+
+func setSubreaper() error {
+	return fmt.Errorf("subreaper is only supported on linux")
+}
+
+// runReaper does nothing as orphaned processes are only reaped on linux
+func runReaper(done chan struct{}) {
+	<-done
+}
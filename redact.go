@@ -0,0 +1,288 @@
+package sidecars
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultRedactMask = "[REDACTED]"
+
+	// minSecretLength is min length of a value to be masked, shorter values would mask common words
+	minSecretLength = 4
+	// redactLookahead is size of data kept after max size of a line before splitting it,
+	// a secret ending in it is never split
+	redactLookahead = 64 * 1024
+)
+
+// DefaultRedactEnvKeys are patterns of env keys whose values are always masked
+var DefaultRedactEnvKeys = []string{"*_PASSWORD", "*_TOKEN"}
+
+// vcapCredentialKeys are parts of keys of VCAP_SERVICES credentials whose values are masked,
+// other values (e.g. host, port or name) are not secrets
+var vcapCredentialKeys = []string{"password", "passwd", "pwd", "secret", "token", "key", "credential"}
+
+// redactor mask secrets in lines of outputs and logs: text matched by configured patterns and values
+// of env vars matching env keys patterns or found in credentials of VCAP_SERVICES
+type redactor struct {
+	mu             sync.RWMutex
+	mask           []byte
+	patterns       []*regexp.Regexp
+	envKeys        []string
+	vcapServices   bool
+	secrets        map[string]bool
+	secretsPattern *regexp.Regexp
+}
+
+// newRedactor give a redactor which already know secrets of launcher env, default secrets are masked
+// when redaction is not configured
+func newRedactor(conf *config.Redact) *redactor {
+	if conf == nil {
+		conf = &config.Redact{}
+	}
+	r := &redactor{
+		mask:         []byte(conf.Mask),
+		envKeys:      append(append([]string{}, DefaultRedactEnvKeys...), conf.EnvKeys...),
+		vcapServices: !conf.NoVcapServices,
+		secrets:      make(map[string]bool),
+	}
+	if conf.Mask == "" {
+		r.mask = []byte(DefaultRedactMask)
+	}
+	for _, pattern := range conf.Patterns {
+		// patterns have been validated when configuration was loaded
+		r.patterns = append(r.patterns, regexp.MustCompile(pattern))
+	}
+	r.addEnv(utils.OsEnvToMap())
+	return r
+}
+
+// addEnv add as secrets values of env vars matching env keys patterns and credentials of VCAP_SERVICES
+func (r *redactor) addEnv(env map[string]string) {
+	if r == nil {
+		return
+	}
+	secrets := make([]string, 0)
+	for k, v := range env {
+		if k == "VCAP_SERVICES" && r.vcapServices {
+			secrets = append(secrets, vcapCredentials(v)...)
+			continue
+		}
+		for _, pattern := range r.envKeys {
+			if ok, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(k)); ok {
+				secrets = append(secrets, v)
+				break
+			}
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := false
+	for _, secret := range secrets {
+		if len(secret) < minSecretLength {
+			continue
+		}
+		// secrets are also masked when they are escaped in a json log entry
+		b, _ := json.Marshal(secret)
+		for _, s := range []string{secret, string(b[1 : len(b)-1])} {
+			if !r.secrets[s] {
+				r.secrets[s] = true
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return
+	}
+	quoted := make([]string, 0, len(r.secrets))
+	for secret := range r.secrets {
+		quoted = append(quoted, regexp.QuoteMeta(secret))
+	}
+	// longest secrets first to fully mask a secret containing another one
+	sort.Slice(quoted, func(i, j int) bool {
+		if len(quoted[i]) != len(quoted[j]) {
+			return len(quoted[i]) > len(quoted[j])
+		}
+		return quoted[i] < quoted[j]
+	})
+	r.secretsPattern = regexp.MustCompile(strings.Join(quoted, "|"))
+}
+
+// vcapCredentials give values of credential keys found in credentials of services and passwords of uris
+func vcapCredentials(vcapServices string) []string {
+	services := make(map[string][]struct {
+		Credentials interface{} `json:"credentials"`
+	})
+	if err := json.Unmarshal([]byte(vcapServices), &services); err != nil {
+		return nil
+	}
+	values := make([]string, 0)
+	var walk func(key string, v interface{})
+	walk = func(key string, v interface{}) {
+		switch t := v.(type) {
+		case string:
+			values = append(values, vcapSecrets(key, t)...)
+		case map[string]interface{}:
+			for subKey, sub := range t {
+				walk(subKey, sub)
+			}
+		case []interface{}:
+			for _, sub := range t {
+				walk(key, sub)
+			}
+		}
+	}
+	for _, instances := range services {
+		for _, instance := range instances {
+			walk("", instance.Credentials)
+		}
+	}
+	return values
+}
+
+// vcapSecrets give secrets of a credential value: whole value for a credential key, password for an uri
+func vcapSecrets(key, value string) []string {
+	key = strings.ToLower(key)
+	for _, credentialKey := range vcapCredentialKeys {
+		if strings.Contains(key, credentialKey) {
+			return []string{value}
+		}
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return nil
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		return nil
+	}
+	secrets := []string{password}
+	// password may be escaped in uri
+	if escaped := strings.TrimPrefix(url.UserPassword("", password).String(), ":"); escaped != password {
+		secrets = append(secrets, escaped)
+	}
+	return secrets
+}
+
+// redact give line with secrets masked, a pattern with groups only mask its groups
+func (r *redactor) redact(line []byte) []byte {
+	if r == nil {
+		return line
+	}
+	r.mu.RLock()
+	secretsPattern := r.secretsPattern
+	r.mu.RUnlock()
+	if secretsPattern != nil {
+		line = secretsPattern.ReplaceAllLiteral(line, r.mask)
+	}
+	for _, pattern := range r.patterns {
+		if pattern.NumSubexp() == 0 {
+			line = pattern.ReplaceAllLiteral(line, r.mask)
+			continue
+		}
+		line = r.redactGroups(pattern, line)
+	}
+	return line
+}
+
+func (r *redactor) redactGroups(pattern *regexp.Regexp, line []byte) []byte {
+	matches := pattern.FindAllSubmatchIndex(line, -1)
+	if len(matches) == 0 {
+		return line
+	}
+	redacted := make([]byte, 0, len(line))
+	last := 0
+	for _, match := range matches {
+		for i := 2; i < len(match); i += 2 {
+			start, end := match[i], match[i+1]
+			// unmatched optional groups and groups nested in an already masked group are skipped
+			if start < 0 || start < last {
+				continue
+			}
+			redacted = append(redacted, line[last:start]...)
+			redacted = append(redacted, r.mask...)
+			last = end
+		}
+	}
+	return append(redacted, line[last:]...)
+}
+
+// cut give where to split a line too long at max without splitting a secret, line is split before the secret
+// which would be split, or at max when secret starts the line
+func (r *redactor) cut(line []byte, max int) int {
+	if r == nil || len(line) <= max {
+		return max
+	}
+	r.mu.RLock()
+	patterns := append([]*regexp.Regexp{}, r.patterns...)
+	if r.secretsPattern != nil {
+		patterns = append(patterns, r.secretsPattern)
+	}
+	r.mu.RUnlock()
+	cut := max
+	for changed := true; changed; {
+		changed = false
+		for _, pattern := range patterns {
+			for _, match := range pattern.FindAllIndex(line, -1) {
+				if match[0] < cut && match[1] > cut && match[0] > 0 {
+					cut = match[0]
+					changed = true
+				}
+			}
+		}
+	}
+	return cut
+}
+
+// lookahead give size of data needed after a cut to not split a secret
+func (r *redactor) lookahead() int {
+	if r == nil {
+		return 0
+	}
+	return redactLookahead
+}
+
+// format give a line format which mask secrets before formatting line
+func (r *redactor) format(format lineFormat) lineFormat {
+	if r == nil {
+		return format
+	}
+	return func(buf, line []byte) []byte {
+		return format(buf, r.redact(line))
+	}
+}
+
+// writer give a writer which mask secrets of each line written, each write must be made of complete lines
+// (e.g. a log entry)
+func (r *redactor) writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return &redactWriter{redactor: r, w: w}
+}
+
+type redactWriter struct {
+	redactor *redactor
+	w        io.Writer
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	lines := bytes.SplitAfter(p, []byte("\n"))
+	redacted := make([]byte, 0, len(p))
+	for _, line := range lines {
+		redacted = append(redacted, w.redactor.redact(line)...)
+	}
+	if _, err := w.w.Write(redacted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
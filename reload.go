@@ -0,0 +1,215 @@
+package sidecars
+
+import (
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"reflect"
+)
+
+// SetConfigLoader set function used to load configuration again when launcher receive SIGHUP,
+// configuration is not reloaded when no loader is set
+func (l *Launcher) SetConfigLoader(loader func() (*config.Sidecars, error)) {
+	l.configLoader = loader
+}
+
+func (l *Launcher) handlingReload(reloadChan chan os.Signal) {
+	stopChan := l.processFactory.StopChan()
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-reloadChan:
+		}
+		err := l.reload()
+		if err != nil {
+			log.WithField("component", "Launcher").WithField("command", "reload").
+				Errorf("Reload rejected, running processes are kept unchanged: %s", err.Error())
+		}
+	}
+}
+
+// reloadPlan is what must be done on running processes to apply a new configuration
+type reloadPlan struct {
+	toStop    []*process
+	toStart   []*process
+	processes []*process
+}
+
+func (r reloadPlan) isEmpty() bool {
+	return len(r.toStop) == 0 && len(r.toStart) == 0
+}
+
+// reload load configuration again and apply difference on running sidecars:
+// added sidecars are started, removed sidecars are stopped and sidecars which changed are restarted
+// with sidecars depending on them.
+// App is restarted only when reverse proxies chain changed.
+// Nothing is changed if new configuration is invalid.
+func (l *Launcher) reload() error {
+	entry := log.WithField("component", "Launcher").WithField("command", "reload")
+	entry.Info("Reloading configuration ...")
+	conf, err := l.configLoader()
+	if err != nil {
+		return err
+	}
+	if conf.NoStarter != l.sConfig.NoStarter || conf.AppPort != l.sConfig.AppPort {
+		entry.Warn("Only sidecars are reloaded, app configuration changes require a restart")
+	}
+	plan, err := l.planReload(conf.Sidecars)
+	if err != nil {
+		return err
+	}
+	if plan.isEmpty() {
+		l.sConfig.Sidecars = conf.Sidecars
+		entry.Info("Nothing to reload.")
+		return nil
+	}
+	select {
+	case <-l.processFactory.StopChan():
+		return fmt.Errorf("launcher is stopping")
+	default:
+	}
+	// launch must not finish while processes are replaced, it fails when all processes already finished
+	if !l.processFactory.hold(1) {
+		return fmt.Errorf("launcher is stopping")
+	}
+	defer l.processFactory.release()
+	// next reload is compared with this configuration, app configuration is kept as it is not reloaded
+	l.sConfig.Sidecars = conf.Sidecars
+	// processes are stopped in reverse order of start as on shutdown
+	for i := len(plan.toStop) - 1; i >= 0; i-- {
+		p := plan.toStop[i]
+		entry.Infof("Stopping %s %s ...", p.typeP, p.name)
+		p.remove()
+	}
+	l.table.set(plan.processes)
+	errChan := l.startProcessesAsync(plan.toStart, plan.processes)
+	go func() {
+		for err := range errChan {
+			if err != nil {
+				entry.Errorf("Error on start after reload: %s", err.Error())
+			}
+		}
+	}()
+	entry.Info("Finished reloading configuration.")
+	return nil
+}
+
+// planReload compare running processes with new sidecars configuration, all new processes are created
+// before anything is changed to not touch running processes if new configuration cannot be applied
+func (l Launcher) planReload(sidecars []*config.Sidecar) (reloadPlan, error) {
+	entry := log.WithField("component", "Launcher").WithField("command", "reload")
+	plan := reloadPlan{
+		toStop:    make([]*process, 0),
+		toStart:   make([]*process, 0),
+		processes: make([]*process, 0),
+	}
+	current := l.table.all()
+	currentByName := make(map[string]*process)
+	for _, p := range current {
+		if p.sidecar != nil {
+			currentByName[p.name] = p
+		}
+	}
+	lEnv, err := l.buildLaunchEnv(sidecars)
+	if err != nil {
+		return plan, err
+	}
+	sorted, err := config.SortByDependencies(launchOrder(sidecars))
+	if err != nil {
+		return plan, err
+	}
+	toCreate := make([]*config.Sidecar, 0)
+	// sidecars are sorted by dependencies, a sidecar depending on a restarted one is known when it is reached
+	restarted := make(map[string]bool)
+	for _, sidecar := range sorted {
+		old, exists := currentByName[sidecar.Name]
+		changed := !exists || !reflect.DeepEqual(*old.sidecar, *sidecar) || !reflect.DeepEqual(old.env, lEnv.sidecarEnvs[sidecar.Name])
+		restartedDep := ""
+		for _, dep := range sidecar.DependsOn {
+			if restarted[dep] {
+				restartedDep = dep
+				break
+			}
+		}
+		if !changed && restartedDep == "" {
+			continue
+		}
+		if sidecar.IsInit() {
+			if !exists {
+				return plan, NewSidecarError(sidecar, fmt.Errorf("init sidecar cannot be added on reload, launcher must be restarted"))
+			}
+			entry.Warnf("Init sidecar %s changed, init sidecars are not run on reload", sidecar.Name)
+			continue
+		}
+		if sidecar.ArtifactURI != "" {
+			if _, err := os.Stat(SidecarDir(l.sConfig.Dir, sidecar.Name)); err != nil {
+				return plan, NewSidecarError(sidecar, fmt.Errorf("artifact is not downloaded, setup must be run before"))
+			}
+		}
+		if exists {
+			if changed {
+				entry.Infof("Sidecar %s changed, it will be restarted", sidecar.Name)
+			} else {
+				entry.Infof("Sidecar %s depends on restarted sidecar %s, it will be restarted", sidecar.Name, restartedDep)
+			}
+			plan.toStop = append(plan.toStop, old)
+			restarted[sidecar.Name] = true
+		} else {
+			entry.Infof("Sidecar %s added, it will be started", sidecar.Name)
+		}
+		toCreate = append(toCreate, sidecar)
+	}
+	for _, p := range current {
+		if p.sidecar == nil {
+			continue
+		}
+		if _, ok := lEnv.sidecarEnvs[p.name]; !ok {
+			entry.Infof("Sidecar %s removed, it will be stopped", p.name)
+			plan.toStop = append(plan.toStop, p)
+		}
+	}
+	// app listen port only change when reverse proxies chain changed
+	app := l.table.app()
+	restartApp := app != nil && app.ports["listen"] != lEnv.appPort
+	if restartApp {
+		entry.Info("Reverse proxies chain changed, app will be restarted")
+	}
+
+	// processes are created only when new configuration is known to be applicable
+	// as each created process open pipes for its output
+	created := make(map[string]*process)
+	for _, sidecar := range toCreate {
+		p, err := l.createSidecarProcess(sidecar, lEnv)
+		if err != nil {
+			return plan, err
+		}
+		created[sidecar.Name] = p
+	}
+	for _, sidecar := range sorted {
+		if p, ok := created[sidecar.Name]; ok {
+			plan.processes = append(plan.processes, p)
+			plan.toStart = append(plan.toStart, p)
+			continue
+		}
+		if old, ok := currentByName[sidecar.Name]; ok {
+			plan.processes = append(plan.processes, old)
+		}
+	}
+	if app == nil {
+		return plan, nil
+	}
+	if restartApp {
+		newApp, err := l.createStarterProcess(lEnv)
+		if err != nil {
+			return plan, err
+		}
+		// app is stopped first and started last
+		plan.toStop = append(plan.toStop, app)
+		plan.toStart = append(plan.toStart, newApp)
+		app = newApp
+	}
+	plan.processes = append(plan.processes, app)
+	return plan, nil
+}
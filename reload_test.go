@@ -0,0 +1,125 @@
+package sidecars
+
+import (
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"io"
+	"sort"
+	"testing"
+)
+
+func TestPlanReloadRestartsDependents(t *testing.T) {
+	sidecars := func(dbArgs ...string) []*config.Sidecar {
+		return []*config.Sidecar{
+			{Name: "db", Executable: "sleep", Args: dbArgs},
+			{Name: "api", Executable: "sleep", Args: []string{"30"}, DependsOn: []string{"db"}},
+			{Name: "worker", Executable: "sleep", Args: []string{"30"}, DependsOn: []string{"api"}},
+			{Name: "other", Executable: "sleep", Args: []string{"30"}},
+		}
+	}
+	l := NewLauncher(config.Sidecars{
+		NoStarter: true,
+		Dir:       t.TempDir(),
+		Sidecars:  sidecars("30"),
+	}, nil, "", io.Discard, io.Discard, 8080)
+	_, processes, err := l.CreateProcesses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.table.set(processes)
+
+	plan, err := l.planReload(sidecars("60"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"api", "db", "worker"}
+	for _, names := range [][]string{processNames(plan.toStop), processNames(plan.toStart)} {
+		sort.Strings(names)
+		if len(names) != len(expected) || names[0] != expected[0] || names[1] != expected[1] || names[2] != expected[2] {
+			t.Errorf("expected db and its dependents to be restarted, got %v", names)
+		}
+	}
+}
+
+func TestPlanReloadRejectsAddedInitSidecar(t *testing.T) {
+	conf := config.Sidecars{
+		NoStarter: true,
+		Dir:       t.TempDir(),
+		Sidecars:  []*config.Sidecar{{Name: "db", Executable: "sleep", Args: []string{"30"}}},
+	}
+	l := NewLauncher(conf, nil, "", io.Discard, io.Discard, 8080)
+	_, processes, err := l.CreateProcesses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.table.set(processes)
+
+	_, err = l.planReload([]*config.Sidecar{
+		{Name: "db", Executable: "sleep", Args: []string{"30"}},
+		{Name: "migrate", Executable: "true", Type: config.SidecarTypeInit},
+		{Name: "api", Executable: "sleep", Args: []string{"30"}, DependsOn: []string{"migrate"}},
+	})
+	if err == nil {
+		t.Fatal("expected reload adding an init sidecar to be rejected")
+	}
+}
+
+func TestDependenciesWithoutProcess(t *testing.T) {
+	api := &process{name: "api", typeP: "sidecar", sidecar: &config.Sidecar{Name: "api", DependsOn: []string{"migrate"}}}
+	deps, err := dependencies(api, []*process{api})
+	if err == nil {
+		t.Fatal("expected error for dependency without process")
+	}
+	if deps != nil {
+		t.Errorf("expected no dependencies, got %v", processNames(deps))
+	}
+}
+
+func TestReloadUpdatesConfig(t *testing.T) {
+	conf := config.Sidecars{
+		NoStarter: true,
+		Dir:       t.TempDir(),
+		Sidecars:  []*config.Sidecar{{Name: "db", Executable: "sleep", Args: []string{"30"}}},
+	}
+	l := NewLauncher(conf, nil, "", io.Discard, io.Discard, 8080)
+	_, processes, err := l.CreateProcesses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.table.set(processes)
+	newConf := conf
+	newConf.Sidecars = []*config.Sidecar{{Name: "db", Executable: "sleep", Args: []string{"30"}}}
+	l.SetConfigLoader(func() (*config.Sidecars, error) {
+		return &newConf, nil
+	})
+	if err := l.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if &l.sConfig.Sidecars[0] != &newConf.Sidecars[0] {
+		t.Error("expected launcher configuration to be updated after reload")
+	}
+}
+
+func TestProcessFactoryHold(t *testing.T) {
+	f := newTestFactory(t)
+	if !f.hold(2) {
+		t.Fatal("expected first hold to succeed")
+	}
+	f.release()
+	if !f.hold(1) {
+		t.Fatal("expected hold to succeed while processes are running")
+	}
+	f.release()
+	f.release()
+	// launcher may be waiting on empty wait group
+	if f.hold(1) {
+		t.Error("expected hold to fail when all processes finished")
+	}
+}
+
+func processNames(processes []*process) []string {
+	names := make([]string, 0, len(processes))
+	for _, p := range processes {
+		names = append(names, p.name)
+	}
+	return names
+}
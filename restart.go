@@ -0,0 +1,80 @@
+package sidecars
+
+import (
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"time"
+)
+
+const (
+	DefaultRestartBackoff     = 1 * time.Second
+	DefaultRestartMaxBackoff  = 1 * time.Minute
+	DefaultRestartResetWindow = 5 * time.Minute
+	// MinRestartBackoff is min wait before a restart, a backoff below is raised to it
+	MinRestartBackoff = 100 * time.Millisecond
+)
+
+type restartPolicy struct {
+	policy      string
+	maxRetries  int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	resetWindow time.Duration
+	attempts    int
+	nextBackoff time.Duration
+}
+
+func newRestartPolicy(sidecar *config.Sidecar) *restartPolicy {
+	if sidecar == nil {
+		return &restartPolicy{policy: config.RestartNever}
+	}
+	policy := sidecar.Restart
+	if policy == "" {
+		policy = config.RestartNever
+	}
+	backoff := sidecar.RestartBackoff.Value(DefaultRestartBackoff)
+	if backoff < MinRestartBackoff {
+		backoff = MinRestartBackoff
+	}
+	maxBackoff := sidecar.RestartMaxBackoff.Value(DefaultRestartMaxBackoff)
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+	return &restartPolicy{
+		policy:      policy,
+		maxRetries:  sidecar.RestartMaxRetries,
+		backoff:     backoff,
+		maxBackoff:  maxBackoff,
+		resetWindow: sidecar.RestartResetWindow.Value(DefaultRestartResetWindow),
+		nextBackoff: backoff,
+	}
+}
+
+// next says if process must be restarted after it exited with err after having run during runtime
+// and how long we should wait before restarting it.
+// Backoff is doubled on each attempt until max backoff and attempts are reset
+// when process has run longer than reset window.
+func (r *restartPolicy) next(err error, runtime time.Duration) (wait time.Duration, restart bool) {
+	switch r.policy {
+	case config.RestartAlways:
+	case config.RestartOnFailure:
+		if err == nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if runtime >= r.resetWindow {
+		r.attempts = 0
+		r.nextBackoff = r.backoff
+	}
+	if r.maxRetries > 0 && r.attempts >= r.maxRetries {
+		return 0, false
+	}
+	r.attempts++
+	wait = r.nextBackoff
+	r.nextBackoff *= 2
+	if r.nextBackoff > r.maxBackoff {
+		r.nextBackoff = r.maxBackoff
+	}
+	return wait, true
+}
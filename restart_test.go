@@ -0,0 +1,75 @@
+package sidecars
+
+import (
+	"errors"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"testing"
+	"time"
+)
+
+func TestRestartPolicyBackoff(t *testing.T) {
+	r := newRestartPolicy(&config.Sidecar{
+		Restart:           config.RestartAlways,
+		RestartBackoff:    "1s",
+		RestartMaxBackoff: "3s",
+	})
+	expected := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	for i, exp := range expected {
+		wait, restart := r.next(nil, 0)
+		if !restart {
+			t.Fatalf("attempt %d: expected a restart", i)
+		}
+		if wait != exp {
+			t.Errorf("attempt %d: expected wait %s, got %s", i, exp, wait)
+		}
+	}
+	// running longer than reset window reset backoff
+	wait, _ := r.next(nil, DefaultRestartResetWindow)
+	if wait != time.Second {
+		t.Errorf("expected backoff to be reset to 1s, got %s", wait)
+	}
+}
+
+func TestRestartPolicyMinBackoff(t *testing.T) {
+	r := newRestartPolicy(&config.Sidecar{
+		Restart:        config.RestartAlways,
+		RestartBackoff: "1ns",
+	})
+	wait, restart := r.next(nil, 0)
+	if !restart || wait != MinRestartBackoff {
+		t.Errorf("expected restart after %s, got restart=%v after %s", MinRestartBackoff, restart, wait)
+	}
+}
+
+func TestRestartPolicyMaxRetries(t *testing.T) {
+	r := newRestartPolicy(&config.Sidecar{
+		Restart:           config.RestartOnFailure,
+		RestartMaxRetries: 2,
+	})
+	if _, restart := r.next(nil, 0); restart {
+		t.Error("on-failure policy must not restart a successful exit")
+	}
+	for i := 0; i < 2; i++ {
+		if _, restart := r.next(errors.New("failed"), 0); !restart {
+			t.Fatalf("attempt %d: expected a restart", i)
+		}
+	}
+	if _, restart := r.next(errors.New("failed"), 0); restart {
+		t.Error("expected no restart after max retries")
+	}
+}
+
+func TestSidecarCheckRejectsZeroBackoff(t *testing.T) {
+	for _, sidecar := range []config.Sidecar{
+		{Name: "a", Executable: "a", Restart: config.RestartAlways, RestartBackoff: "0s"},
+		{Name: "a", Executable: "a", Restart: config.RestartAlways, RestartMaxBackoff: "0"},
+	} {
+		if err := sidecar.Check(); err == nil {
+			t.Errorf("expected error for backoff %q / max backoff %q", sidecar.RestartBackoff, sidecar.RestartMaxBackoff)
+		}
+	}
+	valid := config.Sidecar{Name: "a", Executable: "a", Restart: config.RestartAlways, RestartBackoff: "500ms"}
+	if err := valid.Check(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package sidecars
+
+import (
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// runScheduled run process each time its schedule is reached until all processes are stopping.
+// A failed run is only logged and never stop others processes.
+func (p *process) runScheduled() {
+	entry := log.WithField(p.typeP, p.name)
+	p.startedOnce.Do(func() {
+		close(p.startedChan)
+	})
+	overlap := p.sidecar.ScheduleOverlap
+	if overlap == "" {
+		overlap = config.OverlapSkip
+	}
+	runDone := make(chan error, 1)
+	running := false
+	queued := false
+	nbRuns := 0
+	startRun := func() {
+		nbRuns++
+		running = true
+		// an exec.Cmd cannot be reused, first run use command created with process
+		if nbRuns > 1 {
+			if err := p.rebuild(); err != nil {
+				runDone <- err
+				return
+			}
+		}
+		entry.WithField("run", nbRuns).Infof("Running scheduled %s %s ...", p.typeP, p.name)
+		go func() {
+			runDone <- p.runWithTimeout(p.sidecar.Timeout.Value(0))
+		}()
+	}
+	for {
+		next := p.schedule.Next(time.Now())
+		if next.IsZero() {
+			entry.Errorf("Schedule '%s' will never be reached, %s %s will not run anymore", p.schedule, p.typeP, p.name)
+			select {
+			case <-p.stopChan:
+			case <-p.quitChan:
+			}
+			if running {
+				<-runDone
+			}
+			return
+		}
+		entry.Debugf("Next run of %s %s at %s", p.typeP, p.name, next.Format(time.RFC3339))
+		tick, stopTick := p.scheduleTick(next)
+		select {
+		case <-p.stopChan:
+			stopTick()
+			// process receive stop signal from launcher, we wait for it to finish
+			if running {
+				<-runDone
+			}
+			return
+		case <-p.quitChan:
+			stopTick()
+			// process has been removed, current run receive stop signal, we wait for it to finish
+			if running {
+				<-runDone
+			}
+			return
+		case err := <-runDone:
+			stopTick()
+			running = false
+			status := p.status()
+			runEntry := entry.WithField("run", nbRuns).WithField("exit_code", status.ExitCode)
+			if err != nil {
+				runEntry.Errorf("Scheduled run of %s %s failed after %s: %s", p.typeP, p.name, status.Runtime().Round(time.Millisecond), err.Error())
+				if p.diagnosticDue() {
+					p.logDiagnostic(err)
+				}
+			} else {
+				runEntry.Infof("Scheduled run of %s %s finished in %s", p.typeP, p.name, status.Runtime().Round(time.Millisecond))
+			}
+			if queued {
+				queued = false
+				startRun()
+			}
+		case <-tick:
+			if !running {
+				startRun()
+				continue
+			}
+			switch overlap {
+			case config.OverlapQueue:
+				entry.Warnf("Previous run of %s %s is still running, next run is queued", p.typeP, p.name)
+				queued = true
+			case config.OverlapKill:
+				entry.Warnf("Previous run of %s %s is still running, stopping it ...", p.typeP, p.name)
+				p.stopRun(runDone)
+				running = false
+				startRun()
+			default:
+				entry.Warnf("Previous run of %s %s is still running, skipping this run", p.typeP, p.name)
+			}
+		}
+	}
+}
+
+// scheduleTick give a channel receiving when next run is reached and a func to release it
+func (p *process) scheduleTick(next time.Time) (<-chan time.Time, func()) {
+	if p.scheduleTicks != nil {
+		return p.scheduleTicks, func() {}
+	}
+	timer := time.NewTimer(time.Until(next))
+	return timer.C, func() { timer.Stop() }
+}
+
+// stopRun stop current run of a scheduled process with its stop signal and kill it after its stop timeout
+func (p *process) stopRun(runDone chan error) {
+	entry := log.WithField(p.typeP, p.name)
+	sig := p.stopSignal(nil)
+	if err := p.signal(sig); err != nil {
+		entry.Errorf("failing to send signal '%s' to %s %s: %v", sig, p.typeP, p.name, err)
+	}
+	select {
+	case <-runDone:
+		return
+	case <-time.After(p.killDelay()):
+	}
+	if err := p.kill(); err != nil {
+		entry.Errorf("failed to kill %s %s: %v", p.typeP, p.name, err)
+	}
+	<-runDone
+}
@@ -0,0 +1,87 @@
+package sidecars
+
+import (
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleOverlap(t *testing.T) {
+	// schedule is reached by hand: first tick start a run which lasts until release file exists and
+	// two more ticks are sent while it runs. Each run writes "start" and "end" when it finishes by itself,
+	// a killed run never writes "end".
+	tests := []struct {
+		overlap string
+		// ticks is expected runs output after each tick, ticks after first one are sent while a run is running
+		ticks []string
+		// afterRelease is expected runs output once release file exists
+		afterRelease string
+	}{
+		// skip never start a run while previous one is running
+		{overlap: config.OverlapSkip, ticks: []string{"start", "start", "start"}, afterRelease: "start end"},
+		// queue start one run right after previous one, two ticks give a single queued run
+		{overlap: config.OverlapQueue, ticks: []string{"start", "start", "start"}, afterRelease: "start end start end"},
+		// kill replace running run on each tick
+		{
+			overlap:      config.OverlapKill,
+			ticks:        []string{"start", "start start", "start start start"},
+			afterRelease: "start start start end",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.overlap, func(t *testing.T) {
+			t.Parallel()
+			f := newTestFactory(t)
+			dir := t.TempDir()
+			runsFile := filepath.Join(dir, "runs")
+			releaseFile := filepath.Join(dir, "release")
+			p, err := f.FromSidecar(&config.Sidecar{
+				Name:       "job",
+				Executable: "sh",
+				Args: []string{"-c", "echo start >> " + runsFile +
+					"; while [ ! -e " + releaseFile + " ]; do sleep 0.01; done; echo end >> " + runsFile},
+				Schedule:        "@every 1h",
+				ScheduleOverlap: test.overlap,
+				StopTimeout:     "1s",
+			}, testEnv())
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.scheduleTicks = make(chan time.Time)
+			f.hold(1)
+			go p.Start()
+			defer p.remove()
+			runs := func() string {
+				b, _ := os.ReadFile(runsFile)
+				return strings.Join(strings.Fields(string(b)), " ")
+			}
+			tick := func() {
+				select {
+				case p.scheduleTicks <- time.Now():
+				case <-time.After(5 * time.Second):
+					t.Fatal("scheduler did not wait for schedule")
+				}
+			}
+
+			// a tick is received once previous one is handled
+			for i, expected := range test.ticks {
+				tick()
+				waitFor(t, 5*time.Second, fmt.Sprintf("runs after tick %d", i+1), func() bool { return runs() == expected })
+			}
+			if err := os.WriteFile(releaseFile, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, 5*time.Second, "runs to finish", func() bool { return runs() == test.afterRelease })
+			// no run is started anymore once scheduler is stopped
+			p.remove()
+			if got := runs(); got != test.afterRelease {
+				t.Errorf("expected runs %q with overlap %s, got %q", test.afterRelease, test.overlap, got)
+			}
+		})
+	}
+}
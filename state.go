@@ -0,0 +1,224 @@
+package sidecars
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/orange-cloudfoundry/cloud-sidecars/utils"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func StateFilePath(baseDir string) string {
+	return filepath.Join(baseDir, PathSidecarsWd, "state.json")
+}
+
+// State is the runtime state of a launch written in state file
+type State struct {
+	LauncherPid int            `json:"launcher_pid"`
+	Starter     string         `json:"starter,omitempty"`
+	StartedAt   time.Time      `json:"started_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	FinishedAt  *time.Time     `json:"finished_at,omitempty"`
+	Stale       bool           `json:"stale"`
+	Processes   []ProcessState `json:"processes"`
+}
+
+type ProcessState struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	State      string         `json:"state"`
+	Alive      bool           `json:"alive"`
+	Pid        int            `json:"pid,omitempty"`
+	Pgid       int            `json:"pgid,omitempty"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	ExitedAt   *time.Time     `json:"exited_at,omitempty"`
+	ExitCode   *int           `json:"exit_code,omitempty"`
+	ExitSignal string         `json:"exit_signal,omitempty"`
+	Restarts   int            `json:"restarts"`
+	Ports      map[string]int `json:"ports,omitempty"`
+}
+
+func newProcessState(p *process) ProcessState {
+	status := p.status()
+	ps := ProcessState{
+		Name:       status.Name,
+		Type:       status.Type,
+		State:      status.State,
+		Alive:      status.Running,
+		Pid:        status.Pid,
+		ExitSignal: status.ExitSignal,
+		Restarts:   status.Restarts,
+		Ports:      p.ports,
+	}
+	if status.Running {
+		ps.Pgid = utils.ProcessGroup(status.Pid)
+	}
+	if status.HasRun() {
+		startedAt := status.StartedAt
+		ps.StartedAt = &startedAt
+	}
+	if !status.ExitedAt.IsZero() {
+		exitedAt := status.ExitedAt
+		ps.ExitedAt = &exitedAt
+	}
+	if !status.Running && status.ExitCode >= 0 {
+		exitCode := status.ExitCode
+		ps.ExitCode = &exitCode
+	}
+	return ps
+}
+
+// stateWriter write state file each time a process change
+type stateWriter struct {
+	path       string
+	table      *processTable
+	changeChan chan struct{}
+	state      State
+}
+
+func newStateWriter(path string, table *processTable, changeChan chan struct{}, starterName string) *stateWriter {
+	return &stateWriter{
+		path:       path,
+		table:      table,
+		changeChan: changeChan,
+		state: State{
+			LauncherPid: os.Getpid(),
+			Starter:     starterName,
+			StartedAt:   time.Now(),
+		},
+	}
+}
+
+// Run write state on each change until done is closed, it writes a last time state with finish time
+func (w *stateWriter) Run(done chan struct{}) {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		log.WithField("component", "Launcher").Errorf("failed to create state directory: %v", err)
+	}
+	w.write()
+	for {
+		select {
+		case <-w.changeChan:
+			w.write()
+		case <-done:
+			finishedAt := time.Now()
+			w.state.FinishedAt = &finishedAt
+			w.write()
+			return
+		}
+	}
+}
+
+func (w *stateWriter) write() {
+	processes := make([]ProcessState, 0)
+	for _, p := range w.table.all() {
+		processes = append(processes, newProcessState(p))
+	}
+	w.state.Processes = processes
+	w.state.UpdatedAt = time.Now()
+	b, err := json.MarshalIndent(w.state, "", "  ")
+	if err != nil {
+		log.WithField("component", "Launcher").Errorf("failed to marshal state: %v", err)
+		return
+	}
+	if err := utils.WriteFileAtomic(w.path, b, 0644); err != nil {
+		log.WithField("component", "Launcher").Errorf("failed to write state file '%s': %v", w.path, err)
+	}
+}
+
+// ReadState read state file and check if launcher and processes are still alive,
+// state is stale when launcher which wrote it is dead without having finished
+func ReadState(path string) (State, error) {
+	var state State
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, fmt.Errorf("no state found at '%s', launcher has never been run", path)
+		}
+		return state, err
+	}
+	err = json.Unmarshal(b, &state)
+	if err != nil {
+		return state, fmt.Errorf("invalid state file '%s': %s", path, err.Error())
+	}
+	state.Stale = state.FinishedAt == nil && !utils.ProcessAlive(state.LauncherPid)
+	for i, ps := range state.Processes {
+		state.Processes[i].Alive = ps.Alive && utils.ProcessAlive(ps.Pid)
+	}
+	return state, nil
+}
+
+// ShowStatus write state of last launch read from state file at path as a table or as json
+func ShowStatus(w io.Writer, path string, asJson bool) error {
+	state, err := ReadState(path)
+	if err != nil {
+		return err
+	}
+	if asJson {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(state)
+	}
+	starterName := state.Starter
+	if starterName == "" {
+		starterName = "none"
+	}
+	fmt.Fprintf(w, "Launcher pid %d (starter: %s) started at %s, state updated at %s\n",
+		state.LauncherPid, starterName,
+		state.StartedAt.Format(time.RFC3339), state.UpdatedAt.Format(time.RFC3339))
+	switch {
+	case state.Stale:
+		fmt.Fprintf(w, "WARNING: state is stale, launcher (pid %d) is not running anymore\n", state.LauncherPid)
+	case state.FinishedAt != nil:
+		fmt.Fprintf(w, "Launcher finished at %s\n", state.FinishedAt.Format(time.RFC3339))
+	}
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Process", "Type", "State", "Alive", "Pid", "Pgid", "Uptime", "Restarts", "Exit Code", "Ports"})
+	for _, ps := range state.Processes {
+		pid, pgid, uptime, exitCode := "-", "-", "-", "-"
+		if ps.Pid > 0 {
+			pid = strconv.Itoa(ps.Pid)
+		}
+		if ps.Pgid > 0 {
+			pgid = strconv.Itoa(ps.Pgid)
+		}
+		if ps.Alive && ps.StartedAt != nil {
+			uptime = time.Since(*ps.StartedAt).Round(time.Second).String()
+		}
+		if ps.ExitCode != nil {
+			exitCode = strconv.Itoa(*ps.ExitCode)
+		}
+		alive := "no"
+		if ps.Alive {
+			alive = "yes"
+		}
+		table.Append([]string{
+			ps.Name, ps.Type, ps.State, alive, pid, pgid, uptime,
+			strconv.Itoa(ps.Restarts), exitCode, formatPorts(ps.Ports),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+func formatPorts(ports map[string]int) string {
+	if len(ports) == 0 {
+		return "-"
+	}
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		formatted[i] = fmt.Sprintf("%s=%d", name, ports[name])
+	}
+	return strings.Join(formatted, " ")
+}
@@ -0,0 +1,156 @@
+package sidecars
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deadPid give pid of a process which has exited
+func deadPid(t *testing.T) int {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeTestState(t *testing.T, state State) string {
+	path := StateFilePath(t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStateWriterRoundTrip(t *testing.T) {
+	f := newTestFactory(t)
+	p := startTestSidecar(t, f, "db")
+	p.ports = map[string]int{"http": 8081}
+	waitFor(t, 5*time.Second, "sidecar to run", func() bool { return p.status().Running })
+	table := newProcessTable()
+	table.set([]*process{p})
+
+	path := StateFilePath(t.TempDir())
+	w := newStateWriter(path, table, make(chan struct{}), "test")
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		w.Run(done)
+		close(finished)
+	}()
+	waitFor(t, 5*time.Second, "state to be written", func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	})
+
+	state, err := ReadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LauncherPid != os.Getpid() || state.Starter != "test" || state.Stale || state.FinishedAt != nil {
+		t.Errorf("unexpected launcher state %+v", state)
+	}
+	if len(state.Processes) != 1 {
+		t.Fatalf("expected 1 process in state, got %d", len(state.Processes))
+	}
+	ps := state.Processes[0]
+	if ps.Name != "db" || ps.State != StateRunning || !ps.Alive || ps.Pid != p.status().Pid || ps.Ports["http"] != 8081 {
+		t.Errorf("unexpected process state %+v", ps)
+	}
+
+	close(done)
+	<-finished
+	state, err = ReadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.FinishedAt == nil || state.Stale {
+		t.Errorf("expected state to be finished and not stale after writer stopped, got %+v", state)
+	}
+}
+
+func TestReadStateStale(t *testing.T) {
+	pid := deadPid(t)
+	finishedAt := time.Now()
+	tests := []struct {
+		name       string
+		state      State
+		stale      bool
+		aliveCount int
+	}{
+		{
+			name: "launcher running",
+			state: State{LauncherPid: os.Getpid(), Processes: []ProcessState{
+				{Name: "alive", Alive: true, Pid: os.Getpid()},
+				{Name: "dead", Alive: true, Pid: pid},
+			}},
+			aliveCount: 1,
+		},
+		{
+			name:  "launcher finished",
+			state: State{LauncherPid: pid, FinishedAt: &finishedAt},
+		},
+		{
+			name: "launcher dead without finishing",
+			state: State{LauncherPid: pid, Processes: []ProcessState{
+				{Name: "dead", Alive: true, Pid: pid},
+			}},
+			stale: true,
+		},
+	}
+	for _, test := range tests {
+		state, err := ReadState(writeTestState(t, test.state))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if state.Stale != test.stale {
+			t.Errorf("%s: expected stale to be %t", test.name, test.stale)
+		}
+		alive := 0
+		for _, ps := range state.Processes {
+			if ps.Alive {
+				alive++
+			}
+		}
+		if alive != test.aliveCount {
+			t.Errorf("%s: expected %d alive processes, got %d", test.name, test.aliveCount, alive)
+		}
+	}
+}
+
+func TestReadStateErrors(t *testing.T) {
+	if _, err := ReadState(filepath.Join(t.TempDir(), "state.json")); err == nil || !strings.Contains(err.Error(), "never been run") {
+		t.Errorf("expected error for missing state, got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadState(path); err == nil || !strings.Contains(err.Error(), "invalid state file") {
+		t.Errorf("expected error for invalid state, got %v", err)
+	}
+}
+
+func TestShowStatusStale(t *testing.T) {
+	path := writeTestState(t, State{LauncherPid: deadPid(t), Processes: []ProcessState{{Name: "db", State: StateRunning}}})
+	var buf bytes.Buffer
+	if err := ShowStatus(&buf, path, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "state is stale") || !strings.Contains(buf.String(), "db") {
+		t.Errorf("expected stale warning and process in status, got %q", buf.String())
+	}
+}
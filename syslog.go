@@ -0,0 +1,338 @@
+package sidecars
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultSyslogTimeout   = 5 * time.Second
+	DefaultSyslogQueueSize = 1000
+
+	// syslogDrainTimeout is max time waited on close for pending messages to be sent
+	syslogDrainTimeout = 10 * time.Second
+	syslogMinBackoff   = 1 * time.Second
+	syslogMaxBackoff   = 30 * time.Second
+	// syslogMaxUDPMessage is max size of a message sent over udp, bigger messages are truncated
+	syslogMaxUDPMessage = 65507
+
+	// syslogEnterpriseID is private enterprise number used in id of structured data
+	syslogEnterpriseID = 47450
+	syslogFacilityUser = 1
+	syslogSeverityErr  = 3
+	syslogSeverityInfo = 6
+)
+
+// syslogSink send messages to a syslog server, messages are queued and sent in background,
+// connection is reopened when it fails and messages are dropped when queue is full to never block processes
+type syslogSink struct {
+	mu        sync.Mutex
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	hostname  string
+	timeout   time.Duration
+	queue     chan []byte
+	started   bool
+	closed    bool
+	done      chan struct{}
+	abort     chan struct{}
+	dropped   int64
+	conn      net.Conn
+	failing   bool
+}
+
+func newSyslogSink(conf config.Syslog) *syslogSink {
+	// url has been validated when configuration was loaded
+	u, _ := url.Parse(conf.URL)
+	s := &syslogSink{
+		network:  "tcp",
+		address:  u.Host,
+		hostname: conf.Hostname,
+		timeout:  conf.Timeout.Value(DefaultSyslogTimeout),
+		done:     make(chan struct{}),
+		abort:    make(chan struct{}),
+	}
+	switch u.Scheme {
+	case config.SyslogSchemeUDP:
+		s.network = "udp"
+	case config.SyslogSchemeTLS:
+		s.tlsConfig = &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: conf.TLSSkipVerify,
+		}
+	}
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	queueSize := conf.QueueSize
+	if queueSize == 0 {
+		queueSize = DefaultSyslogQueueSize
+	}
+	s.queue = make(chan []byte, queueSize)
+	return s
+}
+
+func (s *syslogSink) send(msg []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if !s.started {
+		s.started = true
+		go s.run()
+	}
+	select {
+	case s.queue <- msg:
+	default:
+		// dropped messages are only counted here and reported by run, logs can't be written while writing an output
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+func (s *syslogSink) run() {
+	defer close(s.done)
+	for msg := range s.queue {
+		if !s.deliver(msg) {
+			return
+		}
+		if dropped := atomic.SwapInt64(&s.dropped, 0); dropped > 0 {
+			log.WithField("component", "Syslog").Warnf("Syslog queue was full, %d messages have been dropped", dropped)
+		}
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// deliver send message until it succeed, connection is reopened with a backoff when it failed.
+// It returns false when sink is aborted.
+func (s *syslogSink) deliver(msg []byte) bool {
+	entry := log.WithField("component", "Syslog")
+	backoff := syslogMinBackoff
+	for {
+		err := s.write(msg)
+		if err == nil {
+			if s.failing {
+				entry.Infof("Sending messages to syslog %s again", s.address)
+				s.failing = false
+			}
+			return true
+		}
+		if s.conn != nil {
+			s.conn.Close()
+			s.conn = nil
+		}
+		// only first failure is a warning to not flood logs while syslog is unreachable
+		if !s.failing {
+			entry.Warnf("failed to send message to syslog %s, retrying in %s: %v", s.address, backoff, err)
+			s.failing = true
+		} else {
+			entry.Debugf("failed to send message to syslog %s, retrying in %s: %v", s.address, backoff, err)
+		}
+		select {
+		case <-s.abort:
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > syslogMaxBackoff {
+			backoff = syslogMaxBackoff
+		}
+	}
+}
+
+func (s *syslogSink) write(msg []byte) error {
+	if s.conn == nil {
+		var conn net.Conn
+		var err error
+		dialer := &net.Dialer{Timeout: s.timeout}
+		if s.tlsConfig != nil {
+			conn, err = tls.DialWithDialer(dialer, s.network, s.address, s.tlsConfig)
+		} else {
+			conn, err = dialer.Dial(s.network, s.address)
+		}
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	if s.network == "udp" {
+		if len(msg) > syslogMaxUDPMessage {
+			msg = msg[:syslogMaxUDPMessage]
+		}
+		_, err := s.conn.Write(msg)
+		return err
+	}
+	// octet counting framing (RFC 6587)
+	framed := make([]byte, 0, len(msg)+8)
+	framed = append(framed, fmt.Sprintf("%d ", len(msg))...)
+	framed = append(framed, msg...)
+	_, err := s.conn.Write(framed)
+	return err
+}
+
+// Close wait for queued messages to be sent, pending messages are abandoned after a timeout
+func (s *syslogSink) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	started := s.started
+	close(s.queue)
+	s.mu.Unlock()
+	if !started {
+		return
+	}
+	select {
+	case <-s.done:
+	case <-time.After(syslogDrainTimeout):
+		close(s.abort)
+		log.WithField("component", "Syslog").Warnf("Messages not sent to syslog after %s are abandoned", syslogDrainTimeout)
+	}
+}
+
+// syslogWriter send each line written as a RFC 5424 message, app name is name of sidecar, proc id is pid
+// of running process and structured data give name and type of process and stream
+type syslogWriter struct {
+	mu       sync.Mutex
+	sink     *syslogSink
+	redactor *redactor
+	priority string
+	hostname string
+	procID   string
+	header   string
+	partial  []byte
+}
+
+// writer give writer of a stream of app, when sidecar is nil, or of a sidecar, secrets are masked by redactor
+func (s *syslogSink) writer(sidecar *config.Sidecar, stream string, redactor *redactor) *syslogWriter {
+	if s == nil {
+		return nil
+	}
+	name := "app"
+	typeP := "app"
+	if sidecar != nil {
+		name = sidecar.Name
+		typeP = "sidecar"
+	}
+	severity := syslogSeverityInfo
+	if stream == "stderr" {
+		severity = syslogSeverityErr
+	}
+	// header after proc id: msg id and structured data
+	header := fmt.Sprintf(" %s [process@%d name=\"%s\" type=\"%s\" stream=\"%s\"] ",
+		syslogHeaderField(stream, 32),
+		syslogEnterpriseID,
+		syslogParamValue(name), typeP, stream,
+	)
+	return &syslogWriter{
+		sink:     s,
+		redactor: redactor,
+		priority: fmt.Sprintf("<%d>1 ", syslogFacilityUser*8+severity),
+		// hostname and app name following timestamp
+		hostname: fmt.Sprintf(" %s %s ", syslogHeaderField(s.hostname, 255), syslogHeaderField(name, 48)),
+		// nil value until process is started
+		procID: "-",
+		header: header,
+	}
+}
+
+// setPid set pid given as proc id in messages, it must be set each time process is started
+func (w *syslogWriter) setPid(pid int) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.procID = strconv.Itoa(pid)
+}
+
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data := p
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		w.partial = append(w.partial, data[:i]...)
+		splitLine(w.redactor, w.partial, true, w.sendLine)
+		w.partial = w.partial[:0]
+		data = data[i+1:]
+	}
+	w.partial = append(w.partial, data...)
+	rest := splitLine(w.redactor, w.partial, false, w.sendLine)
+	w.partial = append(w.partial[:0], rest...)
+	return len(p), nil
+}
+
+// Flush send line not yet ended
+func (w *syslogWriter) Flush() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) == 0 {
+		return
+	}
+	splitLine(w.redactor, w.partial, true, w.sendLine)
+	w.partial = w.partial[:0]
+}
+
+func (w *syslogWriter) sendLine(line []byte) {
+	line = w.redactor.redact(bytes.TrimSuffix(line, []byte("\r")))
+	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+	msg := make([]byte, 0, len(w.priority)+len(timestamp)+len(w.hostname)+len(w.procID)+len(w.header)+len(line))
+	msg = append(msg, w.priority...)
+	msg = append(msg, timestamp...)
+	msg = append(msg, w.hostname...)
+	msg = append(msg, w.procID...)
+	msg = append(msg, w.header...)
+	msg = append(msg, line...)
+	w.sink.send(msg)
+}
+
+// syslogHeaderField give a header field made of printable us-ascii characters as required by RFC 5424
+func syslogHeaderField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+// syslogParamValue escape characters which must be escaped in a structured data param value
+func syslogParamValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
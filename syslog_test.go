@@ -0,0 +1,129 @@
+package sidecars
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/orange-cloudfoundry/cloud-sidecars/config"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var syslogMessageRegexp = regexp.MustCompile(
+	`^<(\d+)>1 \S+Z myhost my-sidecar (\S+) (\S+) \[process@47450 name="my-sidecar" type="sidecar" stream="(\S+)"\] (.*)$`,
+)
+
+// checkSyslogMessage check a message sent by a writer of sidecar my-sidecar
+func checkSyslogMessage(t *testing.T, msg, priority, procID, stream, line string) {
+	t.Helper()
+	m := syslogMessageRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		t.Fatalf("message is not a valid RFC 5424 message: %q", msg)
+	}
+	if m[1] != priority || m[2] != procID || m[3] != stream || m[4] != stream || m[5] != line {
+		t.Errorf("expected priority %s, proc id %s, stream %s and line %q, got %q", priority, procID, stream, line, msg)
+	}
+}
+
+func writeSyslogLines(conf config.Syslog) *syslogSink {
+	sink := newSyslogSink(conf)
+	sidecar := &config.Sidecar{Name: "my-sidecar"}
+	stdout := sink.writer(sidecar, "stdout", nil)
+	stderr := sink.writer(sidecar, "stderr", nil)
+	_, _ = stdout.Write([]byte("before start\n"))
+	stdout.setPid(1234)
+	stderr.setPid(1234)
+	_, _ = stdout.Write([]byte("first\nsec"))
+	_, _ = stderr.Write([]byte("an error\n"))
+	_, _ = stdout.Write([]byte("ond\n"))
+	return sink
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	msgs := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			// octet counting framing: length, a space and message
+			size, err := r.ReadString(' ')
+			if err != nil {
+				close(msgs)
+				return
+			}
+			n, err := strconv.Atoi(size[:len(size)-1])
+			if err != nil {
+				msgs <- fmt.Sprintf("invalid frame length %q", size)
+				close(msgs)
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				close(msgs)
+				return
+			}
+			msgs <- string(msg)
+		}
+	}()
+
+	sink := writeSyslogLines(config.Syslog{URL: "tcp://" + listener.Addr().String(), Hostname: "myhost"})
+	sink.Close()
+
+	expected := [][]string{
+		{"14", "-", "stdout", "before start"},
+		{"14", "1234", "stdout", "first"},
+		{"11", "1234", "stderr", "an error"},
+		{"14", "1234", "stdout", "second"},
+	}
+	for _, exp := range expected {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				t.Fatal("connection closed before all messages were received")
+			}
+			checkSyslogMessage(t, msg, exp[0], exp[1], exp[2], exp[3])
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %q not received", exp[3])
+		}
+	}
+}
+
+func TestSyslogUDPFraming(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink := writeSyslogLines(config.Syslog{URL: "udp://" + conn.LocalAddr().String(), Hostname: "myhost"})
+	defer sink.Close()
+
+	expected := [][]string{
+		{"14", "-", "stdout", "before start"},
+		{"14", "1234", "stdout", "first"},
+		{"11", "1234", "stderr", "an error"},
+		{"14", "1234", "stdout", "second"},
+	}
+	buf := make([]byte, syslogMaxUDPMessage)
+	for _, exp := range expected {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		// one message per datagram without framing
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("message %q not received: %v", exp[3], err)
+		}
+		checkSyslogMessage(t, string(buf[:n]), exp[0], exp[1], exp[2], exp[3])
+	}
+}
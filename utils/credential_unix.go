@@ -0,0 +1,113 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// CheckCredential check that user, group and supplementary groups given by name or id exist
+func CheckCredential(userName, groupName string, groups []string) error {
+	_, err := lookupCredential(userName, groupName, groups)
+	return err
+}
+
+// lookupCredential give credential of a user and a group given by name or id, group default to primary group of user.
+// Supplementary groups are only those given.
+func lookupCredential(userName, groupName string, groups []string) (*syscall.Credential, error) {
+	cred := &syscall.Credential{
+		Uid:    uint32(os.Getuid()),
+		Gid:    uint32(os.Getgid()),
+		Groups: make([]uint32, 0, len(groups)),
+	}
+	if userName != "" {
+		u, err := lookupUser(userName)
+		if err != nil {
+			return nil, err
+		}
+		if u.Gid == "" && groupName == "" {
+			return nil, fmt.Errorf("user '%s' doesn't exist in system users and has no primary group, group must be set", userName)
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user '%s' has invalid uid '%s'", userName, u.Uid)
+		}
+		cred.Uid = uint32(uid)
+		if gid, err := strconv.ParseUint(u.Gid, 10, 32); err == nil {
+			cred.Gid = uint32(gid)
+		}
+	}
+	if groupName != "" {
+		gid, err := lookupGroupId(groupName)
+		if err != nil {
+			return nil, err
+		}
+		cred.Gid = gid
+	}
+	for _, name := range groups {
+		gid, err := lookupGroupId(name)
+		if err != nil {
+			return nil, err
+		}
+		cred.Groups = append(cred.Groups, gid)
+	}
+	return cred, nil
+}
+
+// CredentialSysProcAttr set credential in sysproc attributes to run process as another user and group,
+// launcher must run as root when credential is not the one of launcher
+func CredentialSysProcAttr(attrOrig *syscall.SysProcAttr, userName, groupName string, groups []string) (*syscall.SysProcAttr, error) {
+	cred, err := lookupCredential(userName, groupName, groups)
+	if err != nil {
+		return attrOrig, err
+	}
+	attr := attrOrig
+	if attr == nil {
+		attr = &syscall.SysProcAttr{}
+	}
+	if os.Geteuid() != 0 && (cred.Uid != uint32(os.Geteuid()) || cred.Gid != uint32(os.Getegid()) || len(cred.Groups) > 0) {
+		return attrOrig, fmt.Errorf(
+			"launcher runs as uid %d and must run as root to run a process as uid %d, gid %d and groups %v",
+			os.Geteuid(), cred.Uid, cred.Gid, cred.Groups,
+		)
+	}
+	// supplementary groups are kept when credential doesn't change, setgroups fails when launcher is not root
+	cred.NoSetGroups = len(cred.Groups) == 0 && cred.Uid == uint32(os.Geteuid()) && cred.Gid == uint32(os.Getegid())
+	attr.Credential = cred
+	return attr, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		u, err := user.LookupId(name)
+		if err != nil {
+			// numeric user may not exist in system users, it then has no primary group
+			return &user.User{Uid: name}, nil
+		}
+		return u, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("user '%s' not found: %s", name, err.Error())
+	}
+	return u, nil
+}
+
+func lookupGroupId(name string) (uint32, error) {
+	if gid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(gid), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("group '%s' not found: %s", name, err.Error())
+	}
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("group '%s' has invalid gid '%s'", name, g.Gid)
+	}
+	return uint32(gid), nil
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+func TestCredentialSysProcAttrSameUser(t *testing.T) {
+	uid := strconv.Itoa(os.Geteuid())
+	gid := strconv.Itoa(os.Getegid())
+	attr, err := CredentialSysProcAttr(nil, uid, gid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attr.Credential.NoSetGroups {
+		t.Error("expected supplementary groups to be kept when credential doesn't change")
+	}
+	// setgroups would fail with EPERM when not root
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = attr
+	if err := cmd.Run(); err != nil {
+		t.Errorf("unexpected error running command as current user: %v", err)
+	}
+}
+
+func TestCredentialSysProcAttrGroups(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("setting supplementary groups require root")
+	}
+	attr, err := CredentialSysProcAttr(nil, "0", "0", []string{"0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attr.Credential.NoSetGroups {
+		t.Error("expected configured supplementary groups to be set")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package utils
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// CheckCredential always fails as running process as another user is not supported on windows
+func CheckCredential(_, _ string, _ []string) error {
+	return fmt.Errorf("running process as another user or group is not supported on windows")
+}
+
+func CredentialSysProcAttr(attrOrig *syscall.SysProcAttr, userName, groupName string, groups []string) (*syscall.SysProcAttr, error) {
+	return attrOrig, CheckCredential(userName, groupName, groups)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package utils
+
+import (
+	"os"
+)
+
+// ProcessAlive says if process with given pid is running
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// ProcessGroup give process group id of process, process groups doesn't exist on windows
+func ProcessGroup(_ int) int {
+	return 0
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+var signals = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"ABRT":  syscall.SIGABRT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"PIPE":  syscall.SIGPIPE,
+	"ALRM":  syscall.SIGALRM,
+	"TERM":  syscall.SIGTERM,
+	"CHLD":  syscall.SIGCHLD,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"TSTP":  syscall.SIGTSTP,
+	"TTIN":  syscall.SIGTTIN,
+	"TTOU":  syscall.SIGTTOU,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// SignalProcess send signal to process, if setpgid is set in sysproc attributes
+// signal is sent to the whole process group (-pid) to also stop all sub process started by this process
+func SignalProcess(p *os.Process, attr *syscall.SysProcAttr, sig os.Signal) error {
+	sysSig, ok := sig.(syscall.Signal)
+	if !ok || !HasPgidSysProcAttr(attr) {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, sysSig)
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"syscall"
@@ -69,3 +71,34 @@ func HasPgidSysProcAttr(attr *syscall.SysProcAttr) bool {
 	valSetpgid := val.FieldByName("Setpgid")
 	return valSetpgid != (reflect.Value{}) && valSetpgid.Kind() == reflect.Bool && valSetpgid.Bool()
 }
+
+// ParseSignal give signal from its name, name can be given with or without SIG prefix (e.g.: SIGTERM, TERM or term)
+func ParseSignal(name string) (syscall.Signal, error) {
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal '%s'", name)
+	}
+	return sig, nil
+}
+
+// WriteFileAtomic write data to a temporary file which is then renamed to path,
+// readers never see a partially written file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,49 +1,309 @@
 package sidecars
 
 import (
-	"bufio"
-	"fmt"
+	"bytes"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
 	"io"
 	"os/exec"
+	"sync"
+	"time"
 )
 
+// maxLogLine is max size of a line written, a longer line is split in lines of at most this size:
+// a line is never lost whatever its size but memory used by a process output stays bounded
+const maxLogLine = 1024 * 1024
+
+// prefixMux serialize outputs written by PrefixCmdOutput
+var prefixMux = newLogMux()
+
+// CmdWriter is kept for compatibility, it is not used anymore
 type CmdWriter struct {
 	// nolint:unused
 	cmd *exec.Cmd
 }
 
+// PrefixCmdOutput write each line of stdout and stderr of cmd prefixed by prefix, lines of all commands
+// using it are never mixed. It must be called before cmd is started.
+//
+// Deprecated: processes created by ProcessFactory write their output through launcher log multiplexer,
+// PrefixCmdOutput is only kept for programs using it and use the same line writer.
 func PrefixCmdOutput(stdout, stderr io.Writer, cmd *exec.Cmd, prefix string) error {
 	stdoutCmd, err := cmd.StdoutPipe()
 	if err != nil {
 		return err
 	}
-
 	stderrCmd, err := cmd.StderrPipe()
 	if err != nil {
 		return err
 	}
+	format := textFormat(prefix)
+	copyLines := func(dst io.Writer, src io.Reader) {
+		w := prefixMux.lineWriter(dst, "command "+cmd.Path, format)
+		_, _ = io.Copy(w, src)
+		w.Close()
+	}
+	go copyLines(stderr, stderrCmd)
+	go copyLines(stdout, stdoutCmd)
+	return nil
+}
 
-	// Created scanners for in, out, and err pipes
-	outScanner := bufio.NewScanner(stdoutCmd)
-	errScanner := bufio.NewScanner(stderrCmd)
+// logMux serialize writes of all processes outputs and of launcher logs to launcher stdout and stderr.
+// Processes outputs are written line by line to never mix lines of different processes.
+type logMux struct {
+	mu      sync.Mutex
+	writers map[*lineWriter]struct{}
+}
 
-	// Scan for text
-	go func() {
-		for errScanner.Scan() {
-			scannerOutput(stderr, prefix, errScanner.Text())
-		}
-	}()
+func newLogMux() *logMux {
+	return &logMux{
+		writers: make(map[*lineWriter]struct{}),
+	}
+}
+
+func (m *logMux) write(dst io.Writer, b []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return dst.Write(b)
+}
+
+// writer give a writer to dst which is serialized with all other writes, each write must be
+// made of complete lines (e.g. a log entry)
+func (m *logMux) writer(dst io.Writer) io.Writer {
+	return &serializedWriter{mux: m, dst: dst}
+}
+
+// lineWriter give a writer to dst which write only complete lines formatted by format,
+// each stream of a process must have its own line writer, name is used in logs
+func (m *logMux) lineWriter(dst io.Writer, name string, format lineFormat) *lineWriter {
+	w := &lineWriter{
+		mux:    m,
+		dst:    dst,
+		name:   name,
+		format: format,
+	}
+	m.mu.Lock()
+	m.writers[w] = struct{}{}
+	m.mu.Unlock()
+	return w
+}
 
-	go func() {
-		for outScanner.Scan() {
-			scannerOutput(stdout, prefix, outScanner.Text())
+// Flush write lines not yet ended of all line writers
+func (m *logMux) Flush() {
+	m.mu.Lock()
+	writers := make([]*lineWriter, 0, len(m.writers))
+	for w := range m.writers {
+		writers = append(writers, w)
+	}
+	m.mu.Unlock()
+	for _, w := range writers {
+		w.Flush()
+	}
+}
+
+type serializedWriter struct {
+	mux *logMux
+	dst io.Writer
+}
+
+func (w *serializedWriter) Write(p []byte) (int, error) {
+	return w.mux.write(w.dst, p)
+}
+
+type lineWriter struct {
+	mu       sync.Mutex
+	mux      *logMux
+	dst      io.Writer
+	name     string
+	format   lineFormat
+	redactor *redactor
+	partial  []byte
+	buf      []byte
+	failing  bool
+}
+
+// Write write all complete lines of p in one write, end of p without end of line is kept
+// until its line is ended, writer is flushed or line is too long
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = w.buf[:0]
+	data := p
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
 		}
-	}()
+		w.partial = append(w.partial, data[:i]...)
+		splitLine(w.redactor, w.partial, true, w.appendLine)
+		w.partial = w.partial[:0]
+		data = data[i+1:]
+	}
+	w.partial = append(w.partial, data...)
+	rest := splitLine(w.redactor, w.partial, false, w.appendLine)
+	w.partial = append(w.partial[:0], rest...)
+	if len(w.buf) == 0 {
+		return len(p), nil
+	}
+	// error of destination is not given back to not stop copy of process output and block process,
+	// next lines will be tried anyway
+	w.checkWrite(w.mux.write(w.dst, w.buf))
+	return len(p), nil
+}
+
+// checkWrite log first error of destination and when it works again, lock must be held.
+// Log is written after write has been released by multiplexer as it is serialized with outputs.
+func (w *lineWriter) checkWrite(_ int, err error) {
+	entry := log.WithField("component", "Output")
+	if err != nil && !w.failing {
+		w.failing = true
+		entry.Errorf("unable to write output of %s, lines are lost until it works again: %v", w.name, err)
+		return
+	}
+	if err == nil && w.failing {
+		w.failing = false
+		entry.Infof("Writing output of %s again", w.name)
+	}
+}
 
+// withRedactor mask secrets in lines written, a line too long is never split in a secret
+func (w *lineWriter) withRedactor(redactor *redactor) *lineWriter {
+	w.format = redactor.format(w.format)
+	w.redactor = redactor
+	return w
+}
+
+// Flush write line not yet ended
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) == 0 {
+		return
+	}
+	w.buf = w.buf[:0]
+	splitLine(w.redactor, w.partial, true, w.appendLine)
+	w.partial = w.partial[:0]
+	w.checkWrite(w.mux.write(w.dst, w.buf))
+}
+
+func (w *lineWriter) appendLine(line []byte) {
+	w.buf = w.format(w.buf, line)
+}
+
+// splitLine give to emit parts of line of at most maxLogLine, a secret is never split. Line not ended
+// is only split when long enough to not cut a secret not yet written, rest of line is returned.
+func splitLine(redactor *redactor, line []byte, ended bool, emit func([]byte)) []byte {
+	for len(line) > maxLogLine && (ended || len(line) >= maxLogLine+redactor.lookahead()) {
+		cut := redactor.cut(line, maxLogLine)
+		emit(line[:cut])
+		line = line[cut:]
+	}
+	if !ended {
+		return line
+	}
+	emit(line)
 	return nil
 }
 
-func scannerOutput(writer io.Writer, prefix string, text string) {
-	out := fmt.Sprintf("%s %s\n", prefix, text)
-	fmt.Fprint(writer, out)
+// Close flush writer and remove it from writers flushed by multiplexer
+func (w *lineWriter) Close() error {
+	w.Flush()
+	w.mux.mu.Lock()
+	delete(w.mux.writers, w)
+	w.mux.mu.Unlock()
+	return nil
+}
+
+// lineFormat append to buf a line of a process output, given without its end of line, as it must be written
+type lineFormat func(buf, line []byte) []byte
+
+// textFormat write lines as is, prefixed by prefix when not empty
+func textFormat(prefix string) lineFormat {
+	if prefix != "" {
+		prefix += " "
+	}
+	return func(buf, line []byte) []byte {
+		buf = append(buf, prefix...)
+		buf = append(buf, line...)
+		return append(buf, '\n')
+	}
+}
+
+// jsonLine is a line of a process output wrapped in json, sidecar is empty for app
+type jsonLine struct {
+	Sidecar   string          `json:"sidecar,omitempty"`
+	App       bool            `json:"app,omitempty"`
+	Stream    string          `json:"stream"`
+	Timestamp string          `json:"timestamp"`
+	Msg       json.RawMessage `json:"msg"`
+}
+
+// jsonFormat write each line as a json object, a line which is already a json object
+// is given as nested object in msg instead of a string
+func jsonFormat(sidecar, stream string) lineFormat {
+	return func(buf, line []byte) []byte {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		jLine := jsonLine{
+			Sidecar:   sidecar,
+			App:       sidecar == "",
+			Stream:    stream,
+			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		}
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+			jLine.Msg = trimmed
+		} else {
+			jLine.Msg, _ = json.Marshal(string(line))
+		}
+		b, err := json.Marshal(jLine)
+		if err != nil {
+			// never lose a line, it is given as text if it cannot be wrapped
+			return textFormat("")(buf, line)
+		}
+		buf = append(buf, b...)
+		return append(buf, '\n')
+	}
+}
+
+// processOutput is output of a process, written to launcher output, kept in a tail for diagnostic
+// and optionally sent to syslog
+type processOutput struct {
+	tail         *outputTail
+	stdout       *lineWriter
+	stderr       *lineWriter
+	syslogStdout *syslogWriter
+	syslogStderr *syslogWriter
+}
+
+// writers give writers to use as stdout and stderr of process
+func (o *processOutput) writers() (io.Writer, io.Writer) {
+	stdout := []io.Writer{o.stdout, o.tail.writer()}
+	stderr := []io.Writer{o.stderr, o.tail.writer()}
+	if o.syslogStdout != nil {
+		stdout = append(stdout, o.syslogStdout)
+		stderr = append(stderr, o.syslogStderr)
+	}
+	return io.MultiWriter(stdout...), io.MultiWriter(stderr...)
+}
+
+// setPid set pid of process which has just been started
+func (o *processOutput) setPid(pid int) {
+	o.syslogStdout.setPid(pid)
+	o.syslogStderr.setPid(pid)
+}
+
+// flush write lines not yet ended, it must be called when process exited
+func (o *processOutput) flush() {
+	o.stdout.Flush()
+	o.stderr.Flush()
+	o.syslogStdout.Flush()
+	o.syslogStderr.Flush()
+}
+
+// close flush output when process will never be run again
+func (o *processOutput) close() {
+	o.stdout.Close()
+	o.stderr.Close()
+	o.syslogStdout.Flush()
+	o.syslogStderr.Flush()
 }
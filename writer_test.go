@@ -0,0 +1,234 @@
+package sidecars
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type failingWriter struct {
+	fail bool
+	buf  bytes.Buffer
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("broken")
+	}
+	return w.buf.Write(p)
+}
+
+// writeChunks write data to w in chunks of size, cutting lines as a pipe would
+func writeChunks(w io.Writer, data []byte, size int) {
+	for i := 0; i < len(data); i += size {
+		end := i + size
+		if end > len(data) {
+			end = len(data)
+		}
+		_, _ = w.Write(data[i:end])
+	}
+}
+
+func TestLineWriterConcurrentWritersNeverInterleave(t *testing.T) {
+	m := newLogMux()
+	var dst bytes.Buffer
+	nbWriters, nbLines := 16, 500
+	var wg sync.WaitGroup
+	for i := 0; i < nbWriters; i++ {
+		lw := m.lineWriter(&dst, fmt.Sprintf("writer %d", i), textFormat(fmt.Sprintf("[w%d]", i)))
+		var data bytes.Buffer
+		for j := 0; j < nbLines; j++ {
+			fmt.Fprintf(&data, "line %d %s\n", j, strings.Repeat("x", j%50))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// chunk size cut lines in the middle
+			writeChunks(lw, data.Bytes(), 7)
+			lw.Close()
+		}()
+	}
+	wg.Wait()
+
+	next := make(map[string]int)
+	scanner := bufio.NewScanner(&dst)
+	for scanner.Scan() {
+		var prefix string
+		var j int
+		var rest string
+		n, _ := fmt.Sscanf(scanner.Text(), "%s line %d %s", &prefix, &j, &rest)
+		if n < 2 || next[prefix] != j || rest != strings.Repeat("x", j%50) {
+			t.Fatalf("line interleaved or out of order: %q", scanner.Text())
+		}
+		next[prefix]++
+	}
+	for i := 0; i < nbWriters; i++ {
+		if got := next[fmt.Sprintf("[w%d]", i)]; got != nbLines {
+			t.Errorf("writer %d: expected %d lines, got %d", i, nbLines, got)
+		}
+	}
+}
+
+func TestLineWriterPartialFinalLine(t *testing.T) {
+	m := newLogMux()
+	var dst bytes.Buffer
+	lw := m.lineWriter(&dst, "test", textFormat("[p]"))
+	_, _ = lw.Write([]byte("first\nsec"))
+	_, _ = lw.Write([]byte("ond"))
+	if dst.String() != "[p] first\n" {
+		t.Fatalf("partial line must not be written before flush, got %q", dst.String())
+	}
+	m.Flush()
+	if dst.String() != "[p] first\n[p] second\n" {
+		t.Fatalf("partial line must be written on flush, got %q", dst.String())
+	}
+	_, _ = lw.Write([]byte("last"))
+	lw.Close()
+	if dst.String() != "[p] first\n[p] second\n[p] last\n" {
+		t.Fatalf("partial line must be written on close, got %q", dst.String())
+	}
+}
+
+func TestLineWriterLongLineIsSplit(t *testing.T) {
+	m := newLogMux()
+	var dst bytes.Buffer
+	lw := m.lineWriter(&dst, "test", textFormat(""))
+	long := strings.Repeat("a", maxLogLine) + strings.Repeat("b", maxLogLine) + "end"
+	writeChunks(lw, []byte(long+"\nnext\n"), 4096)
+	lw.Close()
+
+	lines := strings.Split(strings.TrimSuffix(dst.String(), "\n"), "\n")
+	expected := []string{strings.Repeat("a", maxLogLine), strings.Repeat("b", maxLogLine), "end", "next"}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d", len(expected), len(lines))
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d: expected %d bytes starting with %q, got %d bytes starting with %q",
+				i, len(expected[i]), expected[i][:1], len(lines[i]), lines[i][:1])
+		}
+	}
+}
+
+func TestLineWriterKeepWritingAfterError(t *testing.T) {
+	m := newLogMux()
+	dst := &failingWriter{fail: true}
+	lw := m.lineWriter(dst, "test", textFormat(""))
+	n, err := lw.Write([]byte("lost\n"))
+	if err != nil || n != 5 {
+		t.Fatalf("error of destination must not be given to process, got %d, %v", n, err)
+	}
+	if !lw.failing {
+		t.Fatal("expected writer to be marked as failing")
+	}
+	dst.fail = false
+	_, _ = lw.Write([]byte("kept\n"))
+	if lw.failing {
+		t.Fatal("expected writer to be working again")
+	}
+	if dst.buf.String() != "kept\n" {
+		t.Fatalf("expected line written after error, got %q", dst.buf.String())
+	}
+}
+
+func TestJsonFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		sidecar string
+		line    string
+		msg     string
+	}{
+		{name: "json object nested", sidecar: "db", line: `{"level":"info","n":1}`, msg: `{"level":"info","n":1}`},
+		{name: "json object with spaces", sidecar: "db", line: ` {"a":"b"} `, msg: `{"a":"b"}`},
+		{name: "text escaped", sidecar: "db", line: `say "hi" \ <tag>`, msg: `"say \"hi\" \\ \u003ctag\u003e"`},
+		{name: "invalid json kept as text", sidecar: "db", line: `{"a":`, msg: `"{\"a\":"`},
+		{name: "json array kept as text", sidecar: "db", line: `[1,2]`, msg: `"[1,2]"`},
+		{name: "carriage return trimmed", sidecar: "db", line: "windows line\r", msg: `"windows line"`},
+		{name: "carriage return trimmed before json", line: "{\"a\":1}\r", msg: `{"a":1}`},
+	}
+	for _, test := range tests {
+		b := jsonFormat(test.sidecar, "stderr")(nil, []byte(test.line))
+		if len(b) == 0 || b[len(b)-1] != '\n' || bytes.Count(b, []byte("\n")) != 1 {
+			t.Errorf("%s: expected a single line, got %q", test.name, b)
+			continue
+		}
+		var jLine struct {
+			Sidecar   string          `json:"sidecar"`
+			App       bool            `json:"app"`
+			Stream    string          `json:"stream"`
+			Timestamp string          `json:"timestamp"`
+			Msg       json.RawMessage `json:"msg"`
+		}
+		if err := json.Unmarshal(b, &jLine); err != nil {
+			t.Errorf("%s: invalid json %q: %v", test.name, b, err)
+			continue
+		}
+		if string(jLine.Msg) != test.msg {
+			t.Errorf("%s: expected msg %s, got %s", test.name, test.msg, jLine.Msg)
+		}
+		if jLine.Sidecar != test.sidecar || jLine.App != (test.sidecar == "") || jLine.Stream != "stderr" || jLine.Timestamp == "" {
+			t.Errorf("%s: unexpected fields in %s", test.name, b)
+		}
+	}
+}
+
+const (
+	benchSidecars = 32
+	benchLines    = 2000
+)
+
+func benchOutput() []byte {
+	return []byte(strings.Repeat(strings.Repeat("x", 120)+"\n", benchLines))
+}
+
+// scannerCopy is how outputs were written before log multiplexer: a scanner per stream
+// and a formatted write per line
+func scannerCopy(dst io.Writer, src io.Reader, prefix string) {
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		fmt.Fprint(dst, fmt.Sprintf("%s %s\n", prefix, scanner.Text()))
+	}
+}
+
+func benchmarkOutputs(b *testing.B, copyOutput func(i int, src io.Reader)) {
+	data := benchOutput()
+	b.SetBytes(int64(len(data) * benchSidecars))
+	for n := 0; n < b.N; n++ {
+		var wg sync.WaitGroup
+		for i := 0; i < benchSidecars; i++ {
+			r, w := io.Pipe()
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				writeChunks(w, data, 4096)
+				w.Close()
+			}()
+			go func(i int) {
+				defer wg.Done()
+				copyOutput(i, r)
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkScannerOutput(b *testing.B) {
+	benchmarkOutputs(b, func(i int, src io.Reader) {
+		scannerCopy(io.Discard, src, fmt.Sprintf("[sidecar:s%d]", i))
+	})
+}
+
+func BenchmarkLogMuxOutput(b *testing.B) {
+	m := newLogMux()
+	benchmarkOutputs(b, func(i int, src io.Reader) {
+		lw := m.lineWriter(io.Discard, "bench", textFormat(fmt.Sprintf("[sidecar:s%d]", i)))
+		_, _ = io.Copy(lw, src)
+		lw.Close()
+	})
+}